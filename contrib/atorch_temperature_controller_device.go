@@ -1,6 +1,8 @@
 package contrib
 
 import (
+	"time"
+
 	"tinytuya_go/core"
 )
 
@@ -20,64 +22,92 @@ type AtorchTemperatureControllerDevice struct {
 	*core.Device
 }
 
+// atorchMeterDPS are the metering DPS, which the device only reports on request.
+var atorchMeterDPS = []int{108, 109, 110, 111}
+
 // GetEnergyConsumption returns the energy consumption data.
 func (d *AtorchTemperatureControllerDevice) GetEnergyConsumption() (map[string]interface{}, error) {
-	status, err := d.Status()
-	if err != nil {
-		return nil, err
-	}
-	return status["dps"].(map[string]interface{}), nil
+	return meterDPS(d.Device, atorchMeterDPS...)
 }
 
 // GetCurrent returns the current in mA.
 func (d *AtorchTemperatureControllerDevice) GetCurrent() (float64, error) {
-	status, err := d.Status()
+	dps, err := meterDPS(d.Device, atorchMeterDPS...)
 	if err != nil {
 		return 0, err
 	}
-	current, _ := status["dps"].(map[string]interface{})[DPS_CURRENT].(float64)
-	return current, nil
+	return dps.Float(DPS_CURRENT)
 }
 
 // GetPower returns the power in W.
 func (d *AtorchTemperatureControllerDevice) GetPower() (float64, error) {
-	status, err := d.Status()
+	dps, err := meterDPS(d.Device, atorchMeterDPS...)
 	if err != nil {
 		return 0, err
 	}
-	power, _ := status["dps"].(map[string]interface{})[DPS_POWER].(float64)
-	return power / 100, nil
+	power, err := dps.Float(DPS_POWER)
+	return power / 100, err
 }
 
 // GetVoltage returns the voltage in V.
 func (d *AtorchTemperatureControllerDevice) GetVoltage() (float64, error) {
-	status, err := d.Status()
+	dps, err := meterDPS(d.Device, atorchMeterDPS...)
 	if err != nil {
 		return 0, err
 	}
-	voltage, _ := status["dps"].(map[string]interface{})[DPS_VOLTAGE].(float64)
-	return voltage / 100, nil
+	voltage, err := dps.Float(DPS_VOLTAGE)
+	return voltage / 100, err
 }
 
 // GetTemp returns the current temperature.
 func (d *AtorchTemperatureControllerDevice) GetTemp() (float64, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
 	if err != nil {
 		return 0, err
 	}
-	temp, _ := status["dps"].(map[string]interface{})[DPS_CUR_TEMP].(float64)
-	return temp / 10, nil
+	temp, err := dps.Float(DPS_CUR_TEMP)
+	return temp / 10, err
+}
+
+// GetCurrentTemperature returns the current temperature, like GetTemp.
+func (d *AtorchTemperatureControllerDevice) GetCurrentTemperature() (float64, error) {
+	return d.GetTemp()
+}
+
+// IsOn returns the state of the relay.
+func (d *AtorchTemperatureControllerDevice) IsOn() (bool, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return false, err
+	}
+	return dps.Bool(DPS_SWITCH_STATE)
+}
+
+// SwitchOn turns on the relay.
+func (d *AtorchTemperatureControllerDevice) SwitchOn() (map[string]interface{}, error) {
+	return d.SetValue(103, true)
+}
+
+// SwitchOff turns off the relay.
+func (d *AtorchTemperatureControllerDevice) SwitchOff() (map[string]interface{}, error) {
+	return d.SetValue(103, false)
 }
 
 // GetState returns the current state of the device.
 func (d *AtorchTemperatureControllerDevice) GetState() (map[string]interface{}, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	mode, err := dps.String(DPS_MODE)
 	if err != nil {
 		return nil, err
 	}
-	mode, _ := status["dps"].(map[string]interface{})[DPS_MODE].(string)
 	if mode == "socket" {
-		state, _ := status["dps"].(map[string]interface{})[DPS_SWITCH_STATE].(bool)
+		state, err := dps.Bool(DPS_SWITCH_STATE)
+		if err != nil {
+			return nil, err
+		}
 		statusStr := "off"
 		if state {
 			statusStr = "on"
@@ -86,3 +116,29 @@ func (d *AtorchTemperatureControllerDevice) GetState() (map[string]interface{},
 	}
 	return map[string]interface{}{"mode": mode}, nil
 }
+
+// EnergySample returns the power, voltage and current from a single poll.
+func (d *AtorchTemperatureControllerDevice) EnergySample() (EnergySample, error) {
+	dps, err := meterDPS(d.Device, atorchMeterDPS...)
+	if err != nil {
+		return EnergySample{}, err
+	}
+	current, err := dps.Float(DPS_CURRENT)
+	if err != nil {
+		return EnergySample{}, err
+	}
+	power, err := dps.Float(DPS_POWER)
+	if err != nil {
+		return EnergySample{}, err
+	}
+	voltage, err := dps.Float(DPS_VOLTAGE)
+	if err != nil {
+		return EnergySample{}, err
+	}
+	return EnergySample{
+		Time:    time.Now(),
+		Power:   power / 100,
+		Voltage: voltage / 100,
+		Current: current / 1000,
+	}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"tinytuya_go/core"
 )
@@ -18,6 +19,23 @@ const (
 	BLANKET_LEVEL_PREFIX       = "level_"
 )
 
+// Countdown timers for the body and feet zones, counted in minutes.
+var (
+	blanketBodyCountdown = core.Timer{DPS: 18, Unit: time.Minute}
+	blanketFeetCountdown = core.Timer{DPS: 19, Unit: time.Minute}
+)
+
+// BlanketState is the state of both blanket zones from a single status call.
+// Levels are 0-6, Time is the selected preset and Countdown the time left.
+type BlanketState struct {
+	BodyLevel     int
+	FeetLevel     int
+	BodyTime      time.Duration
+	FeetTime      time.Duration
+	BodyCountdown time.Duration
+	FeetCountdown time.Duration
+}
+
 // BlanketDevice represents a Tuya based Electric Blanket Device.
 type BlanketDevice struct {
 	*core.Device
@@ -36,23 +54,45 @@ func (d *BlanketDevice) levelToNumber(level string) (int, error) {
 	return num - 1, nil
 }
 
+// timeToPreset converts a duration to a "Nh" time preset.
+func (d *BlanketDevice) timeToPreset(dur time.Duration) (string, error) {
+	if dur%time.Hour != 0 || dur < time.Hour || dur > 12*time.Hour {
+		return "", fmt.Errorf("time needs to be a whole number of hours between 1 and 12")
+	}
+	return fmt.Sprintf("%dh", dur/time.Hour), nil
+}
+
+func (d *BlanketDevice) presetToTime(preset string) (time.Duration, error) {
+	hours, err := strconv.Atoi(strings.TrimSuffix(preset, "h"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time preset %q", preset)
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
 // GetFeetLevel returns the feet level.
 func (d *BlanketDevice) GetFeetLevel() (int, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
+	if err != nil {
+		return 0, err
+	}
+	level, err := dps.String(BLANKET_DPS_FEET_LEVEL)
 	if err != nil {
 		return 0, err
 	}
-	level, _ := status["dps"].(map[string]interface{})[BLANKET_DPS_FEET_LEVEL].(string)
 	return d.levelToNumber(level)
 }
 
 // GetBodyLevel returns the body level.
 func (d *BlanketDevice) GetBodyLevel() (int, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
+	if err != nil {
+		return 0, err
+	}
+	level, err := dps.String(BLANKET_DPS_BODY_LEVEL)
 	if err != nil {
 		return 0, err
 	}
-	level, _ := status["dps"].(map[string]interface{})[BLANKET_DPS_BODY_LEVEL].(string)
 	return d.levelToNumber(level)
 }
 
@@ -71,3 +111,103 @@ func (d *BlanketDevice) SetBodyLevel(num int) (map[string]interface{}, error) {
 	}
 	return d.SetValue(14, d.numberToLevel(num))
 }
+
+// GetFeetCountdown returns the time remaining on the feet countdown.
+func (d *BlanketDevice) GetFeetCountdown() (time.Duration, error) {
+	return d.GetCountdownTimer(blanketFeetCountdown)
+}
+
+// GetBodyCountdown returns the time remaining on the body countdown.
+func (d *BlanketDevice) GetBodyCountdown() (time.Duration, error) {
+	return d.GetCountdownTimer(blanketBodyCountdown)
+}
+
+// GetFeetTime returns the feet time preset.
+func (d *BlanketDevice) GetFeetTime() (time.Duration, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return 0, err
+	}
+	preset, err := dps.String(BLANKET_DPS_FEET_TIME)
+	if err != nil {
+		return 0, err
+	}
+	return d.presetToTime(preset)
+}
+
+// GetBodyTime returns the body time preset.
+func (d *BlanketDevice) GetBodyTime() (time.Duration, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return 0, err
+	}
+	preset, err := dps.String(BLANKET_DPS_BODY_TIME)
+	if err != nil {
+		return 0, err
+	}
+	return d.presetToTime(preset)
+}
+
+// SetFeetTime sets the feet time preset, 1 to 12 hours.
+func (d *BlanketDevice) SetFeetTime(dur time.Duration) (map[string]interface{}, error) {
+	preset, err := d.timeToPreset(dur)
+	if err != nil {
+		return nil, err
+	}
+	return d.SetValue(17, preset)
+}
+
+// SetBodyTime sets the body time preset, 1 to 12 hours.
+func (d *BlanketDevice) SetBodyTime(dur time.Duration) (map[string]interface{}, error) {
+	preset, err := d.timeToPreset(dur)
+	if err != nil {
+		return nil, err
+	}
+	return d.SetValue(16, preset)
+}
+
+// State returns the levels, time presets and countdowns of both zones.
+func (d *BlanketDevice) State() (*BlanketState, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &BlanketState{}
+	level, err := dps.String(BLANKET_DPS_BODY_LEVEL)
+	if err != nil {
+		return nil, err
+	}
+	if state.BodyLevel, err = d.levelToNumber(level); err != nil {
+		return nil, fmt.Errorf("invalid body level %q", level)
+	}
+	if level, err = dps.String(BLANKET_DPS_FEET_LEVEL); err != nil {
+		return nil, err
+	}
+	if state.FeetLevel, err = d.levelToNumber(level); err != nil {
+		return nil, fmt.Errorf("invalid feet level %q", level)
+	}
+	preset, err := dps.String(BLANKET_DPS_BODY_TIME)
+	if err != nil {
+		return nil, err
+	}
+	if state.BodyTime, err = d.presetToTime(preset); err != nil {
+		return nil, err
+	}
+	if preset, err = dps.String(BLANKET_DPS_FEET_TIME); err != nil {
+		return nil, err
+	}
+	if state.FeetTime, err = d.presetToTime(preset); err != nil {
+		return nil, err
+	}
+	countdown, err := dps.Int(BLANKET_DPS_BODY_COUNTDOWN)
+	if err != nil {
+		return nil, err
+	}
+	state.BodyCountdown = time.Duration(countdown) * blanketBodyCountdown.Unit
+	if countdown, err = dps.Int(BLANKET_DPS_FEET_COUNTDOWN); err != nil {
+		return nil, err
+	}
+	state.FeetCountdown = time.Duration(countdown) * blanketFeetCountdown.Unit
+	return state, nil
+}
@@ -0,0 +1,117 @@
+package contrib
+
+import (
+	"context"
+
+	"tinytuya_go/core"
+)
+
+// Switchable is a device which can be switched on and off.
+type Switchable interface {
+	SwitchOn() (map[string]interface{}, error)
+	SwitchOff() (map[string]interface{}, error)
+	IsOn() (bool, error)
+}
+
+// PowerMeter is a device which measures power.
+type PowerMeter interface {
+	EnergyMeter
+	GetPower() (float64, error) // W
+}
+
+// TemperatureSensor is a device which measures temperature.
+type TemperatureSensor interface {
+	GetCurrentTemperature() (float64, error)
+}
+
+// Thermostat is a device which controls towards a target temperature.
+// Temperatures are in the unit the device is set to.
+type Thermostat interface {
+	TemperatureSensor
+	GetTargetTemperature() (float64, error)
+	SetTargetTemperature(t float64) (map[string]interface{}, error)
+}
+
+// Dimmable is a light whose brightness can be set.
+type Dimmable interface {
+	SetBrightnessPercentage(pct int) (map[string]interface{}, error)
+}
+
+// ColorLight is a light whose colour can be set.
+type ColorLight interface {
+	SetColour(r, g, b int) (map[string]interface{}, error)
+}
+
+// IRBlaster is a device which learns and sends remote control buttons.
+type IRBlaster interface {
+	ReceiveButton(ctx context.Context) (string, error)
+	SendButton(base64Code string) (map[string]interface{}, error)
+}
+
+// Capability names returned by Capabilities.
+const (
+	CAPABILITY_SWITCHABLE         = "switchable"
+	CAPABILITY_POWER_METER        = "power_meter"
+	CAPABILITY_TEMPERATURE_SENSOR = "temperature_sensor"
+	CAPABILITY_THERMOSTAT         = "thermostat"
+	CAPABILITY_DIMMABLE           = "dimmable"
+	CAPABILITY_COLOR_LIGHT        = "color_light"
+	CAPABILITY_IR_BLASTER         = "ir_blaster"
+)
+
+var (
+	_ Switchable = (*AtorchTemperatureControllerDevice)(nil)
+	_ Switchable = (*core.BulbDevice)(nil)
+	_ Switchable = (*ClimateDevice)(nil)
+	_ Switchable = (*ColorfulX7Device)(nil)
+	_ Switchable = (*InverterHeatPumpDevice)(nil)
+	_ Switchable = (*core.OutletDevice)(nil)
+	_ Switchable = (*SocketDevice)(nil)
+
+	_ PowerMeter = (*AtorchTemperatureControllerDevice)(nil)
+	_ PowerMeter = (*SocketDevice)(nil)
+	_ PowerMeter = (*WiFiDualMeterDevice)(nil)
+
+	_ TemperatureSensor = (*AtorchTemperatureControllerDevice)(nil)
+
+	_ Thermostat = (*ClimateDevice)(nil)
+	_ Thermostat = (*InverterHeatPumpDevice)(nil)
+	_ Thermostat = (*ThermostatDevice)(nil)
+
+	_ Dimmable = (*core.BulbDevice)(nil)
+	_ Dimmable = (*ColorfulX7Device)(nil)
+
+	_ ColorLight = (*core.BulbDevice)(nil)
+	_ ColorLight = (*ColorfulX7Device)(nil)
+
+	_ IRBlaster = (*IRRemoteControlDevice)(nil)
+	_ IRBlaster = (*RFRemoteControlDevice)(nil)
+)
+
+// Capabilities returns the CAPABILITY_* names of the interfaces a device,
+// such as one returned by New, implements.
+func Capabilities(dev interface{}) []string {
+	var caps []string
+	if _, ok := dev.(Switchable); ok {
+		caps = append(caps, CAPABILITY_SWITCHABLE)
+	}
+	if _, ok := dev.(PowerMeter); ok {
+		caps = append(caps, CAPABILITY_POWER_METER)
+	}
+	if _, ok := dev.(TemperatureSensor); ok {
+		caps = append(caps, CAPABILITY_TEMPERATURE_SENSOR)
+	}
+	if _, ok := dev.(Thermostat); ok {
+		caps = append(caps, CAPABILITY_THERMOSTAT)
+	}
+	if _, ok := dev.(Dimmable); ok {
+		caps = append(caps, CAPABILITY_DIMMABLE)
+	}
+	if _, ok := dev.(ColorLight); ok {
+		caps = append(caps, CAPABILITY_COLOR_LIGHT)
+	}
+	if _, ok := dev.(IRBlaster); ok {
+		caps = append(caps, CAPABILITY_IR_BLASTER)
+	}
+	return caps
+}
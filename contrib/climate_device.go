@@ -2,6 +2,9 @@ package contrib
 
 import (
 	"fmt"
+	"math"
+	"strings"
+	"time"
 
 	"tinytuya_go/core"
 )
@@ -14,47 +17,115 @@ const (
 	CLIMATE_DPS_FAN       = "5"
 	CLIMATE_DPS_TEMP_UNIT = "19"
 	CLIMATE_DPS_TIMER     = "22"
+	CLIMATE_DPS_SLEEP     = "25"
+	CLIMATE_DPS_SWING     = "30"
 	CLIMATE_DPS_STATE     = "101"
 )
 
+// CLIMATE_FAN_SPEEDS are the fan speeds accepted by SetFanSpeed.
+var CLIMATE_FAN_SPEEDS = []string{"auto", "low", "middle", "high"}
+
+// climateTimer is the shutdown timer DPS, set in whole hours up to 24.
+var climateTimer = core.Timer{DPS: 22, Unit: time.Hour, Max: 24 * time.Hour}
+
 // ClimateDevice represents a Tuya based Air Conditioner.
 type ClimateDevice struct {
 	*core.Device
 }
 
-// GetRoomTemperature returns the room temperature.
+// tempScale returns the power of ten a temperature DPS is scaled by, taken
+// from the mapping.
+func (d *ClimateDevice) tempScale(id string) float64 {
+	scale, _ := d.Mapping[id].ValueFloat("scale")
+	return math.Pow(10, scale)
+}
+
+// GetRoomTemperature returns the room temperature, in the unit returned by
+// GetTemperatureUnit.
 func (d *ClimateDevice) GetRoomTemperature() (float64, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
 	if err != nil {
 		return 0, err
 	}
-	temp, _ := status["dps"].(map[string]interface{})[CLIMATE_DPS_CUR_TEMP].(float64)
-	return temp, nil
+	temp, err := dps.Float(CLIMATE_DPS_CUR_TEMP)
+	if err != nil {
+		return 0, err
+	}
+	return temp / d.tempScale(CLIMATE_DPS_CUR_TEMP), nil
 }
 
-// GetTargetTemperature returns the target temperature.
+// GetCurrentTemperature returns the room temperature, like
+// GetRoomTemperature.
+func (d *ClimateDevice) GetCurrentTemperature() (float64, error) {
+	return d.GetRoomTemperature()
+}
+
+// GetTargetTemperature returns the target temperature, in the unit returned
+// by GetTemperatureUnit.
 func (d *ClimateDevice) GetTargetTemperature() (float64, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
+	if err != nil {
+		return 0, err
+	}
+	temp, err := dps.Float(CLIMATE_DPS_SET_TEMP)
 	if err != nil {
 		return 0, err
 	}
-	temp, _ := status["dps"].(map[string]interface{})[CLIMATE_DPS_SET_TEMP].(float64)
-	return temp, nil
+	return temp / d.tempScale(CLIMATE_DPS_SET_TEMP), nil
 }
 
-// SetTargetTemperature sets the target temperature.
+// SetTargetTemperature sets the target temperature in the unit the device
+// is set to. The value is scaled as given by the mapping; without a scale it
+// is sent as a whole number if the device reports whole numbers.
 func (d *ClimateDevice) SetTargetTemperature(t float64) (map[string]interface{}, error) {
-	return d.SetValue(2, t)
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	return d.setTargetTemperature(t, dps)
+}
+
+// SetTargetTemperatureIn sets the target temperature given in unit, "c" or
+// "f", converting it to the unit the device is set to.
+func (d *ClimateDevice) SetTargetTemperatureIn(t float64, unit string) (map[string]interface{}, error) {
+	unit = strings.ToLower(unit)
+	if unit != "c" && unit != "f" {
+		return nil, fmt.Errorf("unit must be c or f")
+	}
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	deviceUnit, err := dps.String(CLIMATE_DPS_TEMP_UNIT)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case unit == "c" && strings.EqualFold(deviceUnit, "f"):
+		t = t*1.8 + 32
+	case unit == "f" && strings.EqualFold(deviceUnit, "c"):
+		t = (t - 32) / 1.8
+	}
+	return d.setTargetTemperature(t, dps)
+}
+
+func (d *ClimateDevice) setTargetTemperature(t float64, dps core.DPS) (map[string]interface{}, error) {
+	var value interface{} = t
+	if m, ok := d.Mapping[CLIMATE_DPS_SET_TEMP]; ok && (m.Type == "Integer" || m.Type == "Value") {
+		value = int(math.Round(t * d.tempScale(CLIMATE_DPS_SET_TEMP)))
+	} else if cur, err := dps.Float(CLIMATE_DPS_SET_TEMP); err == nil && cur == math.Trunc(cur) {
+		value = int(math.Round(t))
+	}
+	return d.NewBatch().Set(CLIMATE_DPS_SET_TEMP, value).Send()
 }
 
 // GetOperatingMode returns the operating mode.
 func (d *ClimateDevice) GetOperatingMode() (string, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
 	if err != nil {
 		return "", err
 	}
-	mode, _ := status["dps"].(map[string]interface{})[CLIMATE_DPS_MODE].(string)
-	return mode, nil
+	return dps.String(CLIMATE_DPS_MODE)
 }
 
 // SetOperatingMode sets the operating mode.
@@ -64,3 +135,79 @@ func (d *ClimateDevice) SetOperatingMode(mode string) (map[string]interface{}, e
 	}
 	return d.SetValue(4, mode)
 }
+
+// GetTimer returns the time remaining on the shutdown timer.
+func (d *ClimateDevice) GetTimer() (time.Duration, error) {
+	return d.GetCountdownTimer(climateTimer)
+}
+
+// SetTimer sets the shutdown timer, rounded to whole hours.
+func (d *ClimateDevice) SetTimer(dur time.Duration) (map[string]interface{}, error) {
+	return d.SetCountdownTimer(climateTimer, dur)
+}
+
+// GetFanSpeed returns the fan speed.
+func (d *ClimateDevice) GetFanSpeed() (string, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return "", err
+	}
+	return dps.String(CLIMATE_DPS_FAN)
+}
+
+// SetFanSpeed sets the fan speed to auto | low | middle | high.
+func (d *ClimateDevice) SetFanSpeed(speed string) (map[string]interface{}, error) {
+	if !containsString(CLIMATE_FAN_SPEEDS, speed) {
+		return nil, fmt.Errorf("invalid fan speed %q, supported speeds %v", speed, CLIMATE_FAN_SPEEDS)
+	}
+	return d.SetValue(5, speed)
+}
+
+// IsOn returns the power state.
+func (d *ClimateDevice) IsOn() (bool, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return false, err
+	}
+	return dps.Bool(CLIMATE_DPS_POWER)
+}
+
+// SwitchOn turns on the unit.
+func (d *ClimateDevice) SwitchOn() (map[string]interface{}, error) {
+	return d.SetValue(1, true)
+}
+
+// SwitchOff turns off the unit.
+func (d *ClimateDevice) SwitchOff() (map[string]interface{}, error) {
+	return d.SetValue(1, false)
+}
+
+// GetCurrentState returns "On" or "Off".
+func (d *ClimateDevice) GetCurrentState() (string, error) {
+	on, err := d.IsOn()
+	if err != nil {
+		return "", err
+	}
+	if on {
+		return "On", nil
+	}
+	return "Off", nil
+}
+
+// GetTemperatureUnit returns the temperature unit, "c" or "f".
+func (d *ClimateDevice) GetTemperatureUnit() (string, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return "", err
+	}
+	return dps.String(CLIMATE_DPS_TEMP_UNIT)
+}
+
+// SetTemperatureUnit sets the temperature unit to "c" or "f", in either case.
+func (d *ClimateDevice) SetTemperatureUnit(unit string) (map[string]interface{}, error) {
+	unit = strings.ToLower(unit)
+	if unit != "c" && unit != "f" {
+		return nil, fmt.Errorf("unit must be c or f")
+	}
+	return d.SetValue(19, unit)
+}
@@ -2,21 +2,44 @@ package contrib
 
 import (
 	"fmt"
+	"time"
 
 	"tinytuya_go/core"
 )
 
 const (
-	COLORFULX7_DPS_INDEX_ON          = "20"
-	COLORFULX7_DPS_INDEX_MODE        = "21"
-	COLORFULX7_DPS_INDEX_COLOUR      = "24"
-	COLORFULX7_DPS_INDEX_COUNTDOWN   = "26"
-	COLORFULX7_DPS_INDEX_WORKMODE    = "104"
-	COLORFULX7_DPS_INDEX_BRIGHTNESS  = "106"
-	COLORFULX7_DPS_INDEX_DYNAMIC_MODE = "108"
-	COLORFULX7_DPS_INDEX_MUSIC_MODE   = "109"
+	COLORFULX7_DPS_INDEX_ON                 = "20"
+	COLORFULX7_DPS_INDEX_MODE               = "21"
+	COLORFULX7_DPS_INDEX_COLOUR             = "24"
+	COLORFULX7_DPS_INDEX_COUNTDOWN          = "26"
+	COLORFULX7_DPS_INDEX_MUSIC_DATA         = "27"
+	COLORFULX7_DPS_INDEX_SEG_NUM            = "101"
+	COLORFULX7_DPS_INDEX_SEG_LED_NUM        = "102"
+	COLORFULX7_DPS_INDEX_RGB_ORDER          = "103"
+	COLORFULX7_DPS_INDEX_WORKMODE           = "104"
+	COLORFULX7_DPS_INDEX_COLOUR_RGB         = "105"
+	COLORFULX7_DPS_INDEX_BRIGHTNESS         = "106"
+	COLORFULX7_DPS_INDEX_DYNAMIC_INTV       = "107"
+	COLORFULX7_DPS_INDEX_DYNAMIC_MODE       = "108"
+	COLORFULX7_DPS_INDEX_MUSIC_MODE         = "109"
+	COLORFULX7_DPS_INDEX_SENSITIVITY        = "110"
+	COLORFULX7_DPS_INDEX_MUSIC_COLOR        = "111"
+	COLORFULX7_DPS_INDEX_LED_BRAND          = "112"
+	COLORFULX7_DPS_INDEX_SCREEN_POINT_COLOR = "113"
+	COLORFULX7_DPS_INDEX_SCREEN_MODE        = "114"
 )
 
+var (
+	COLORFULX7_MODES      = []string{"white", "colour", "scene", "music", "screen"}
+	COLORFULX7_RGB_ORDERS = []string{"ORDER_RGB", "ORDER_RBG", "ORDER_GRB", "ORDER_GBR", "ORDER_BRG", "ORDER_BGR"}
+	COLORFULX7_WORKMODES  = []string{"CLOSE", "FIX_COLOR", "DYNAMIC", "MUSIC", "SCREEN"}
+	// the app only shows these brands, the device may support more
+	COLORFULX7_LED_BRANDS = []string{"WS2811", "DMX512", "FW1935"}
+)
+
+// colorfulX7Countdown is the countdown timer DPS, in seconds up to 24 hours.
+var colorfulX7Countdown = core.Timer{DPS: 26, Unit: time.Second, Max: 24 * time.Hour}
+
 // ColorfulX7Device represents a Tuya based LED Music Controller.
 type ColorfulX7Device struct {
 	*core.Device
@@ -24,12 +47,11 @@ type ColorfulX7Device struct {
 
 // IsOn returns the state of the device.
 func (d *ColorfulX7Device) IsOn() (bool, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
 	if err != nil {
 		return false, err
 	}
-	on, _ := status["dps"].(map[string]interface{})[COLORFULX7_DPS_INDEX_ON].(bool)
-	return on, nil
+	return dps.Bool(COLORFULX7_DPS_INDEX_ON)
 }
 
 // SwitchOff turns off the device.
@@ -44,15 +66,69 @@ func (d *ColorfulX7Device) SwitchOn() (map[string]interface{}, error) {
 
 // SetMode sets the mode to white | colour | scene | music | screen.
 func (d *ColorfulX7Device) SetMode(mode string) (map[string]interface{}, error) {
+	if !containsString(COLORFULX7_MODES, mode) {
+		return nil, fmt.Errorf("unsupported mode %q, supported modes %v", mode, COLORFULX7_MODES)
+	}
 	return d.SetValue(21, mode)
 }
 
 // SetColor sets the colour.
 func (d *ColorfulX7Device) SetColor(r, g, b int) (map[string]interface{}, error) {
-	// hsv := rgbToHSV(r, g, b)
-	// hex := hsvToHex(hsv)
-	// return d.SetValue(24, hex)
-	return nil, fmt.Errorf("color conversion not implemented")
+	if err := checkRGB(r, g, b); err != nil {
+		return nil, err
+	}
+	return d.SetValue(24, ColorfulX7HSVHex(r, g, b))
+}
+
+// SetCountdown sets the countdown timer.
+func (d *ColorfulX7Device) SetCountdown(dur time.Duration) (map[string]interface{}, error) {
+	return d.SetCountdownTimer(colorfulX7Countdown, dur)
+}
+
+// GetCountdown returns the time remaining on the countdown timer.
+func (d *ColorfulX7Device) GetCountdown() (time.Duration, error) {
+	return d.GetCountdownTimer(colorfulX7Countdown)
+}
+
+// SetSegmentsNumber sets the number of segments in the LED strip or matrix.
+func (d *ColorfulX7Device) SetSegmentsNumber(number int) (map[string]interface{}, error) {
+	if number < 1 || number > 64 {
+		return nil, fmt.Errorf("number of segments must be between 1 and 64")
+	}
+	return d.SetValue(101, number)
+}
+
+// SetLedsPerSegment sets the number of LEDs per segment in the LED strip or
+// matrix.
+func (d *ColorfulX7Device) SetLedsPerSegment(number int) (map[string]interface{}, error) {
+	if number < 1 || number > 150 {
+		return nil, fmt.Errorf("number of LEDs per segment must be between 1 and 150")
+	}
+	return d.SetValue(102, number)
+}
+
+// SetRGBOrder sets the RGB order of the LEDs, one of COLORFULX7_RGB_ORDERS.
+func (d *ColorfulX7Device) SetRGBOrder(order string) (map[string]interface{}, error) {
+	if !containsString(COLORFULX7_RGB_ORDERS, order) {
+		return nil, fmt.Errorf("unsupported RGB order %q, supported orders %v", order, COLORFULX7_RGB_ORDERS)
+	}
+	return d.SetValue(103, order)
+}
+
+// SetWorkMode sets the work mode to CLOSE | FIX_COLOR | DYNAMIC | MUSIC | SCREEN.
+func (d *ColorfulX7Device) SetWorkMode(mode string) (map[string]interface{}, error) {
+	if !containsString(COLORFULX7_WORKMODES, mode) {
+		return nil, fmt.Errorf("unsupported work mode %q, supported modes %v", mode, COLORFULX7_WORKMODES)
+	}
+	return d.SetValue(104, mode)
+}
+
+// SetColorRGB sets the colour in CLOSE | FIX_COLOR | DYNAMIC work modes.
+func (d *ColorfulX7Device) SetColorRGB(r, g, b int) (map[string]interface{}, error) {
+	if err := checkRGB(r, g, b); err != nil {
+		return nil, err
+	}
+	return d.SetValue(105, ColorfulX7RGBHex(r, g, b))
 }
 
 // SetBrightness sets the brightness.
@@ -63,7 +139,26 @@ func (d *ColorfulX7Device) SetBrightness(value int) (map[string]interface{}, err
 	return d.SetValue(106, value)
 }
 
-// SetDynamicMode sets the scene type in DYNAMIC work mode.
+// SetBrightnessPercentage sets the brightness, like SetBrightness.
+func (d *ColorfulX7Device) SetBrightnessPercentage(pct int) (map[string]interface{}, error) {
+	return d.SetBrightness(pct)
+}
+
+// SetColour sets the colour, like SetColor.
+func (d *ColorfulX7Device) SetColour(r, g, b int) (map[string]interface{}, error) {
+	return d.SetColor(r, g, b)
+}
+
+// SetSpeed sets the speed in DYNAMIC work mode.
+func (d *ColorfulX7Device) SetSpeed(value int) (map[string]interface{}, error) {
+	if value < 0 || value > 100 {
+		return nil, fmt.Errorf("speed must be between 0 and 100")
+	}
+	return d.SetValue(107, value)
+}
+
+// SetDynamicMode sets the scene type in DYNAMIC work mode, one of the 180
+// numbered scenes in the app. Only SetSpeed and SetBrightness affect it.
 func (d *ColorfulX7Device) SetDynamicMode(mode int) (map[string]interface{}, error) {
 	if mode < 1 || mode > 180 {
 		return nil, fmt.Errorf("dynamic mode must be between 1 and 180")
@@ -71,7 +166,8 @@ func (d *ColorfulX7Device) SetDynamicMode(mode int) (map[string]interface{}, err
 	return d.SetValue(108, mode)
 }
 
-// SetMusicMode sets the scene type in MUSIC work mode.
+// SetMusicMode sets the scene type in MUSIC work mode, one of 22 numbered
+// strip modes. SetSensitivity and, for some modes, SetMusicRGBColor affect it.
 func (d *ColorfulX7Device) SetMusicMode(mode int) (map[string]interface{}, error) {
 	if mode < 1 || mode > 22 {
 		return nil, fmt.Errorf("music mode must be between 1 and 22")
@@ -79,3 +175,71 @@ func (d *ColorfulX7Device) SetMusicMode(mode int) (map[string]interface{}, error
 	return d.SetValue(109, mode)
 }
 
+// SetSensitivity sets the microphone sensitivity in MUSIC | SCREEN work modes.
+func (d *ColorfulX7Device) SetSensitivity(value int) (map[string]interface{}, error) {
+	if value < 0 || value > 100 {
+		return nil, fmt.Errorf("sensitivity must be between 0 and 100")
+	}
+	return d.SetValue(110, value)
+}
+
+// SetMusicRGBColor sets the colour used by some scenes in MUSIC | SCREEN work
+// modes.
+func (d *ColorfulX7Device) SetMusicRGBColor(r, g, b int) (map[string]interface{}, error) {
+	if err := checkRGB(r, g, b); err != nil {
+		return nil, err
+	}
+	return d.SetValue(111, ColorfulX7RGBHex(r, g, b))
+}
+
+// SetLedBrand sets the LED brand to WS2811 | DMX512 | FW1935.
+func (d *ColorfulX7Device) SetLedBrand(brand string) (map[string]interface{}, error) {
+	if !containsString(COLORFULX7_LED_BRANDS, brand) {
+		return nil, fmt.Errorf("unsupported LED brand %q, supported brands %v", brand, COLORFULX7_LED_BRANDS)
+	}
+	return d.SetValue(112, brand)
+}
+
+// SetScreenMode sets the scene type in SCREEN work mode, one of 30 numbered
+// matrix modes. SetSensitivity and SetFallingDotColor affect it.
+func (d *ColorfulX7Device) SetScreenMode(mode int) (map[string]interface{}, error) {
+	if mode < 1 || mode > 30 {
+		return nil, fmt.Errorf("screen mode must be between 1 and 30")
+	}
+	return d.SetValue(114, mode)
+}
+
+// SetFallingDotColor sets the falling dot colour used by some scenes in SCREEN
+// work mode.
+func (d *ColorfulX7Device) SetFallingDotColor(r, g, b int) (map[string]interface{}, error) {
+	if err := checkRGB(r, g, b); err != nil {
+		return nil, err
+	}
+	return d.SetValue(113, ColorfulX7HSVHex(r, g, b))
+}
+
+// ColorfulX7HSVHex converts an RGB colour to the hhhhssssvvvv hex format used
+// by the colour DPS, with hue in degrees and saturation and value out of 1000.
+func ColorfulX7HSVHex(r, g, b int) string {
+	h, s, v := core.RGBToHSV(r, g, b)
+	return fmt.Sprintf("%04x%04x%04x", int(h*360), int(s*1000), int(v*1000))
+}
+
+// ColorfulX7RGBHex converts an RGB colour to the #rrggbb format used by the
+// RGB colour DPS.
+func ColorfulX7RGBHex(r, g, b int) string {
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+func checkRGB(r, g, b int) error {
+	if r < 0 || r > 255 {
+		return fmt.Errorf("red must be between 0 and 255")
+	}
+	if g < 0 || g > 255 {
+		return fmt.Errorf("green must be between 0 and 255")
+	}
+	if b < 0 || b > 255 {
+		return fmt.Errorf("blue must be between 0 and 255")
+	}
+	return nil
+}
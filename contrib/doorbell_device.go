@@ -1,18 +1,137 @@
 package contrib
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"tinytuya_go/core"
 )
 
 const (
-	DOORBELL_DPS_BASIC_INDICATOR   = "101"
-	DOORBELL_DPS_VOLUME              = "160"
-	DOORBELL_DPS_MOTION_AREA         = "169"
+	DOORBELL_DPS_BASIC_INDICATOR    = "101"
+	DOORBELL_DPS_MOTION_PIC         = "115"
+	DOORBELL_DPS_MOTION_SWITCH      = "134"
+	DOORBELL_DPS_ACTIVE             = "136"
+	DOORBELL_DPS_VOLUME             = "160"
+	DOORBELL_DPS_MOTION_AREA        = "169"
 	DOORBELL_DPS_MOTION_AREA_SWITCH = "168"
+	DOORBELL_DPS_ALARM_MESSAGE      = "185"
 )
 
+// Doorbell event types.
+const (
+	DOORBELL_EVENT_RING   = "ring"
+	DOORBELL_EVENT_MOTION = "motion"
+	DOORBELL_EVENT_ALARM  = "alarm"
+)
+
+// doorbellEventDPS maps the event DPS to their event type.
+var doorbellEventDPS = map[string]string{
+	DOORBELL_DPS_ACTIVE:        DOORBELL_EVENT_RING,
+	DOORBELL_DPS_MOTION_PIC:    DOORBELL_EVENT_MOTION,
+	DOORBELL_DPS_ALARM_MESSAGE: DOORBELL_EVENT_ALARM,
+}
+
+const (
+	// doorbellMotionAreaMaxLen is the longest value DPS 169 accepts
+	doorbellMotionAreaMaxLen = 255
+	// doorbellMaxMotionRegions is the most regions which fit in DPS 169,
+	// going by the shortest encoding of a region
+	doorbellMaxMotionRegions = doorbellMotionAreaMaxLen / len(`,"region0":{"x":0,"y":0,"xlen":0,"ylen":0}`)
+	// doorbellHeartbeatInterval keeps the connection open while watching
+	doorbellHeartbeatInterval = 10 * time.Second
+)
+
+// MotionRegion is a motion detection rectangle, in percent of the picture.
+type MotionRegion struct {
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	XLen int `json:"xlen"`
+	YLen int `json:"ylen"`
+}
+
+// MotionRegions is the set of motion detection regions of DPS 169.
+type MotionRegions []MotionRegion
+
+// DoorbellEvent is a ring, motion or alarm reported by the doorbell. Value is
+// the raw DPS value, usually a Base64 or JSON encoded message.
+type DoorbellEvent struct {
+	Time  time.Time
+	Type  string // DOORBELL_EVENT_*
+	Value string
+}
+
+// Validate checks every region lies within the 0-100 grid. Regions of size 0
+// and an empty set, encoded as "num":0, are allowed, as the device reports
+// them.
+func (r MotionRegions) Validate() error {
+	for i, reg := range r {
+		if reg.X < 0 || reg.Y < 0 || reg.XLen < 0 || reg.YLen < 0 {
+			return fmt.Errorf("region %d: position and size must not be negative", i)
+		}
+		if reg.X+reg.XLen > 100 || reg.Y+reg.YLen > 100 {
+			return fmt.Errorf("region %d: must lie within 0-100", i)
+		}
+	}
+	return nil
+}
+
+// Encode returns the DPS 169 value, such as
+// {"num":1,"region0":{"x":0,"y":0,"xlen":50,"ylen":100}}.
+func (r MotionRegions) Encode() (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `{"num":%d`, len(r))
+	for i, reg := range r {
+		region, err := json.Marshal(reg)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, `,"region%d":%s`, i, region)
+	}
+	b.WriteString("}")
+	if b.Len() > doorbellMotionAreaMaxLen {
+		return "", fmt.Errorf("too many motion regions, encoded value exceeds %d characters", doorbellMotionAreaMaxLen)
+	}
+	return b.String(), nil
+}
+
+// ParseMotionRegions decodes a DPS 169 value and checks it as Validate does.
+func ParseMotionRegions(s string) (MotionRegions, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("invalid motion area: %w", err)
+	}
+	var num int
+	if err := json.Unmarshal(raw["num"], &num); err != nil {
+		return nil, fmt.Errorf("invalid motion area count: %w", err)
+	}
+	if num < 0 || num > doorbellMaxMotionRegions {
+		return nil, fmt.Errorf("invalid motion area count %d, must be between 0 and %d", num, doorbellMaxMotionRegions)
+	}
+	var regions MotionRegions
+	for i := 0; i < num; i++ {
+		var reg MotionRegion
+		data, ok := raw[fmt.Sprintf("region%d", i)]
+		if !ok {
+			return nil, fmt.Errorf("motion area is missing region%d", i)
+		}
+		if err := json.Unmarshal(data, &reg); err != nil {
+			return nil, fmt.Errorf("invalid motion region%d: %w", i, err)
+		}
+		regions = append(regions, reg)
+	}
+	if err := regions.Validate(); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
 // DoorbellDevice represents a Tuya based Video-Doorbell.
 type DoorbellDevice struct {
 	*core.Device
@@ -34,13 +153,77 @@ func (d *DoorbellDevice) SetVolume(vol int) (map[string]interface{}, error) {
 	return d.SetValue(160, vol)
 }
 
-// SetMotionArea sets the area of motion detection.
+// SetMotionArea sets a single area of motion detection, in percent. Values
+// are clamped to 0-100, and an area reaching past 100 is replaced with 25-100.
 func (d *DoorbellDevice) SetMotionArea(x, y, xlen, ylen int) (map[string]interface{}, error) {
-	data := fmt.Sprintf(`{"num":1,"region0":{"x":%d,"y":%d,"xlen":%d,"ylen":%d}}`, x, y, xlen, ylen)
+	x, y = clampPercent(x), clampPercent(y)
+	xlen, ylen = clampPercent(xlen), clampPercent(ylen)
+	if x+xlen > 100 {
+		x, xlen = 25, 75
+	}
+	if y+ylen > 100 {
+		y, ylen = 25, 75
+	}
+	return d.SetMotionRegions(MotionRegions{{X: x, Y: y, XLen: xlen, YLen: ylen}})
+}
+
+// SetMotionRegions sets the areas of motion detection.
+func (d *DoorbellDevice) SetMotionRegions(regions MotionRegions) (map[string]interface{}, error) {
+	data, err := regions.Encode()
+	if err != nil {
+		return nil, err
+	}
 	return d.SetValue(169, data)
 }
 
+// GetMotionRegions returns the current areas of motion detection.
+func (d *DoorbellDevice) GetMotionRegions() (MotionRegions, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	area, err := dps.String(DOORBELL_DPS_MOTION_AREA)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMotionRegions(area)
+}
+
+func clampPercent(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 100 {
+		return 100
+	}
+	return v
+}
+
 // SetMotionAreaSwitch turns the motion detection area on/off.
 func (d *DoorbellDevice) SetMotionAreaSwitch(useArea bool) (map[string]interface{}, error) {
 	return d.SetValue(168, useArea)
 }
+
+// SetMotionSwitch turns the alarm on motion detection on/off.
+func (d *DoorbellDevice) SetMotionSwitch(on bool) (map[string]interface{}, error) {
+	return d.SetValue(134, on)
+}
+
+// WatchEvents calls fn with ring, motion and alarm events until ctx is done
+// or the connection fails. Events are received as the device reports them,
+// so the device should be created with a persistent connection.
+func (d *DoorbellDevice) WatchEvents(ctx context.Context, fn func(DoorbellEvent)) error {
+	return d.Watch(ctx, doorbellHeartbeatInterval, func(dps map[string]interface{}) {
+		for _, id := range []string{DOORBELL_DPS_ACTIVE, DOORBELL_DPS_MOTION_PIC, DOORBELL_DPS_ALARM_MESSAGE} {
+			if !core.DPS(dps).Has(id) {
+				continue
+			}
+			value, err := core.DPS(dps).String(id)
+			if err != nil {
+				// keep events reported with other types rather than drop them
+				value = fmt.Sprint(dps[id])
+			}
+			fn(DoorbellEvent{Time: time.Now(), Type: doorbellEventDPS[id], Value: value})
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package contrib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"tinytuya_go/core"
+)
+
+// EnergySample is a power reading normalised across meter types.
+type EnergySample struct {
+	Time    time.Time
+	Power   float64 // W, negative while exporting
+	Voltage float64 // V
+	Current float64 // A
+}
+
+// EnergyMeter is a device which can be polled for power readings. New meter
+// types only need to implement it to be used with EnergyMonitor.
+type EnergyMeter interface {
+	EnergySample() (EnergySample, error)
+}
+
+var (
+	_ EnergyMeter = (*SocketDevice)(nil)
+	_ EnergyMeter = (*AtorchTemperatureControllerDevice)(nil)
+	_ EnergyMeter = (*WiFiDualMeterDevice)(nil)
+)
+
+// meterDPS asks the device to refresh the given metering DPS and returns the
+// DPS of the device status with the refreshed values merged over it. Devices
+// which ignore the request are read from the status alone.
+func meterDPS(d *core.Device, ids ...int) (core.DPS, error) {
+	update, err := d.UpdateDPS(ids...)
+	if err != nil && !errors.Is(err, core.ErrTimeout) {
+		return nil, err
+	}
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	if update != nil {
+		refreshed, _ := update["dps"].(map[string]interface{})
+		for k, v := range refreshed {
+			dps[k] = v
+		}
+	}
+	return dps, nil
+}
+
+// EnergyCounter is the accumulated energy of a meter.
+type EnergyCounter struct {
+	ImportKWh  float64   `json:"import_kwh"`
+	ExportKWh  float64   `json:"export_kwh"`
+	LastPower  float64   `json:"last_power"`
+	LastSample time.Time `json:"last_sample"`
+}
+
+// EnergyMonitor polls meters on a schedule and integrates their power into
+// imported and exported kWh. Counters are saved to Path after each poll, if
+// set, so they survive restarts.
+type EnergyMonitor struct {
+	Interval time.Duration
+	Path     string
+	// MaxGap is the longest time between two samples which is integrated,
+	// to avoid counting across downtime. It defaults to three intervals.
+	MaxGap time.Duration
+	// OnSample, if set, is called with each sample.
+	OnSample func(id string, s EnergySample)
+	// OnError, if set, is called with poll and save errors while running.
+	OnError func(err error)
+
+	mu       sync.Mutex
+	meters   map[string]EnergyMeter
+	counters map[string]*EnergyCounter
+}
+
+// NewEnergyMonitor creates an EnergyMonitor polling every interval, loading
+// any counters saved in path. An empty path disables persistence.
+func NewEnergyMonitor(interval time.Duration, path string) (*EnergyMonitor, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive")
+	}
+	m := &EnergyMonitor{
+		Interval: interval,
+		Path:     path,
+		meters:   make(map[string]EnergyMeter),
+		counters: make(map[string]*EnergyCounter),
+	}
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.counters); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Add registers a meter under id, keeping any saved counter for that id.
+func (m *EnergyMonitor) Add(id string, meter EnergyMeter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.meters[id] = meter
+	if _, ok := m.counters[id]; !ok {
+		m.counters[id] = &EnergyCounter{}
+	}
+}
+
+// Remove stops polling the meter registered under id. Its counter is kept.
+func (m *EnergyMonitor) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.meters, id)
+}
+
+// Counters returns a copy of the counters, keyed by meter id.
+func (m *EnergyMonitor) Counters() map[string]EnergyCounter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]EnergyCounter, len(m.counters))
+	for id, c := range m.counters {
+		out[id] = *c
+	}
+	return out
+}
+
+// Reset zeroes the counter of a meter.
+func (m *EnergyMonitor) Reset(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.counters[id]; ok {
+		m.counters[id] = &EnergyCounter{}
+	}
+}
+
+// Poll samples every meter once, updates the counters and saves them.
+// Meters which fail are skipped and their errors returned together.
+func (m *EnergyMonitor) Poll() error {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.meters))
+	for id := range m.meters {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+	sort.Strings(ids)
+
+	var errs []error
+	for _, id := range ids {
+		m.mu.Lock()
+		meter, ok := m.meters[id]
+		m.mu.Unlock()
+		if !ok {
+			continue
+		}
+		s, err := meter.EnergySample()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("meter %s: %w", id, err))
+			continue
+		}
+		if s.Time.IsZero() {
+			s.Time = time.Now()
+		}
+		m.record(id, s)
+		if m.OnSample != nil {
+			m.OnSample(id, s)
+		}
+	}
+	if err := m.Save(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// record integrates a sample into the counter of a meter, using the average
+// of the previous and current power over the time between them. An interval
+// in which the power changes sign is split where it crosses zero, so import
+// and export are booked separately.
+func (m *EnergyMonitor) record(id string, s EnergySample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[id]
+	if !ok {
+		c = &EnergyCounter{}
+		m.counters[id] = c
+	}
+	maxGap := m.MaxGap
+	if maxGap == 0 {
+		maxGap = 3 * m.Interval
+	}
+	if !c.LastSample.IsZero() {
+		dt := s.Time.Sub(c.LastSample)
+		if dt > 0 && dt <= maxGap {
+			p0, p1, h := c.LastPower, s.Power, dt.Hours()
+			if p0*p1 >= 0 {
+				c.book((p0 + p1) / 2 * h / 1000)
+			} else {
+				// fraction of the interval before the zero crossing
+				f := p0 / (p0 - p1)
+				c.book(p0 / 2 * f * h / 1000)
+				c.book(p1 / 2 * (1 - f) * h / 1000)
+			}
+		}
+	}
+	c.LastPower = s.Power
+	c.LastSample = s.Time
+}
+
+// book adds energy to the import counter, or to the export counter if it is
+// negative.
+func (c *EnergyCounter) book(kwh float64) {
+	if kwh >= 0 {
+		c.ImportKWh += kwh
+	} else {
+		c.ExportKWh -= kwh
+	}
+}
+
+// Save writes the counters to Path, if set.
+func (m *EnergyMonitor) Save() error {
+	if m.Path == "" {
+		return nil
+	}
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m.counters, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// write a temporary file first so a crash cannot leave a partial file
+	tmp := m.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.Path)
+}
+
+// Run polls the meters every Interval until ctx is done. Errors are passed to
+// OnError and do not stop polling.
+func (m *EnergyMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Poll(); err != nil && m.OnError != nil {
+			m.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package contrib
 
 import (
+	"errors"
+	"fmt"
+
 	"tinytuya_go/core"
 )
 
@@ -20,7 +23,13 @@ const (
 	UNKNOWN InverterHeatPumpMode = "unknown"
 )
 
-// InverterHeatPumpFault represents the fault of the inverter.
+// IsKnown reports whether the mode is one of the documented modes.
+func (m InverterHeatPumpMode) IsKnown() bool {
+	return m == HEATING || m == UNKNOWN
+}
+
+// InverterHeatPumpFault represents the fault of the inverter. The fault DPS
+// is a bitmap, so several faults may be active at once.
 type InverterHeatPumpFault int
 
 const (
@@ -29,17 +38,67 @@ const (
 	UNKNOWN_FAULT InverterHeatPumpFault = -1
 )
 
+var inverterHeatPumpFaultNames = map[InverterHeatPumpFault]string{
+	NOMINAL:       "nominal",
+	NO_WATER_FLOW: "no water flow",
+	UNKNOWN_FAULT: "unknown",
+}
+
+// IsKnown reports whether the fault is one of the documented faults.
+func (f InverterHeatPumpFault) IsKnown() bool {
+	_, ok := inverterHeatPumpFaultNames[f]
+	return ok
+}
+
+// String returns the name of the fault, or its code if unknown.
+func (f InverterHeatPumpFault) String() string {
+	if name, ok := inverterHeatPumpFaultNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("fault 0x%x", int(f))
+}
+
+// DecodeInverterHeatPumpFaults splits a fault bitmap into single faults. It
+// returns nil when there is no fault.
+func DecodeInverterHeatPumpFaults(bitmap int) []InverterHeatPumpFault {
+	var faults []InverterHeatPumpFault
+	for bit := 0; bit < 32; bit++ {
+		if bitmap&(1<<bit) != 0 {
+			faults = append(faults, InverterHeatPumpFault(1<<bit))
+		}
+	}
+	return faults
+}
+
+// InverterHeatPumpState is the state of the heat pump from a single status
+// call. Temperatures are in Unit.
+type InverterHeatPumpState struct {
+	On                     bool
+	Unit                   TemperatureUnit
+	InletWaterTemp         float64
+	TargetWaterTemp        float64
+	LowerLimitTargetTemp   float64
+	UpperLimitTargetTemp   float64
+	HeatingCapacityPercent int
+	Mode                   InverterHeatPumpMode // UNKNOWN if RawMode is not known
+	RawMode                string
+	Fault                  int // raw fault bitmap
+	Faults                 []InverterHeatPumpFault
+	SilenceMode            bool
+}
+
 const (
-	INVERTER_DPS_ON_DP                        = "1"
-	INVERTER_DPS_INLET_WATER_TEMP_DP          = "102"
-	INVERTER_DPS_UNIT_DP                        = "103"
-	INVERTER_DPS_HEATING_CAPACITY_PERCENT_DP    = "104"
-	INVERTER_DPS_MODE_DP                        = "105"
-	INVERTER_DPS_TARGET_WATER_TEMP_DP         = "106"
+	INVERTER_DPS_ON_DP                            = "1"
+	INVERTER_DPS_INLET_WATER_TEMP_DP              = "102"
+	INVERTER_DPS_UNIT_DP                          = "103"
+	INVERTER_DPS_HEATING_CAPACITY_PERCENT_DP      = "104"
+	INVERTER_DPS_MODE_DP                          = "105"
+	INVERTER_DPS_TARGET_WATER_TEMP_DP             = "106"
 	INVERTER_DPS_LOWER_LIMIT_TARGET_WATER_TEMP_DP = "107"
 	INVERTER_DPS_UPPER_LIMIT_TARGET_WATER_TEMP_DP = "108"
-	INVERTER_DPS_FAULT_DP                       = "115"
-	INVERTER_DPS_SILENCE_MODE_DP                = "117"
+	INVERTER_DPS_FAULT_DP                         = "115"
+	INVERTER_DPS_FAULT2_DP                        = "116"
+	INVERTER_DPS_SILENCE_MODE_DP                  = "117"
 )
 
 // InverterHeatPumpDevice represents a Tuya WiFi smart inverter heat pump.
@@ -49,25 +108,225 @@ type InverterHeatPumpDevice struct {
 
 // IsOn returns True if the inverter is on.
 func (d *InverterHeatPumpDevice) IsOn() (bool, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
 	if err != nil {
 		return false, err
 	}
-	on, _ := status["dps"].(map[string]interface{})[INVERTER_DPS_ON_DP].(bool)
-	return on, nil
+	return dps.Bool(INVERTER_DPS_ON_DP)
+}
+
+// SwitchOn turns on the heat pump.
+func (d *InverterHeatPumpDevice) SwitchOn() (map[string]interface{}, error) {
+	return d.SetValue(1, true)
+}
+
+// SwitchOff turns off the heat pump.
+func (d *InverterHeatPumpDevice) SwitchOff() (map[string]interface{}, error) {
+	return d.SetValue(1, false)
 }
 
 // GetUnit returns the unit of the temperature.
 func (d *InverterHeatPumpDevice) GetUnit() (TemperatureUnit, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
+	if err != nil {
+		return false, err
+	}
+	unit, err := dps.Bool(INVERTER_DPS_UNIT_DP)
 	if err != nil {
 		return false, err
 	}
-	unit, _ := status["dps"].(map[string]interface{})[INVERTER_DPS_UNIT_DP].(bool)
 	return TemperatureUnit(unit), nil
 }
 
-// SetTargetWaterTemp sets the target water temperature.
+// State returns the full state of the heat pump. Missing or malformed DPS
+// are all reported in the error.
+func (d *InverterHeatPumpDevice) State() (*InverterHeatPumpState, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	num := func(id string) float64 {
+		v, err := dps.Float(id)
+		errs = append(errs, err)
+		return v
+	}
+	flag := func(id string) bool {
+		v, err := dps.Bool(id)
+		errs = append(errs, err)
+		return v
+	}
+
+	state := &InverterHeatPumpState{
+		On:                     flag(INVERTER_DPS_ON_DP),
+		Unit:                   TemperatureUnit(flag(INVERTER_DPS_UNIT_DP)),
+		InletWaterTemp:         num(INVERTER_DPS_INLET_WATER_TEMP_DP),
+		TargetWaterTemp:        num(INVERTER_DPS_TARGET_WATER_TEMP_DP),
+		LowerLimitTargetTemp:   num(INVERTER_DPS_LOWER_LIMIT_TARGET_WATER_TEMP_DP),
+		UpperLimitTargetTemp:   num(INVERTER_DPS_UPPER_LIMIT_TARGET_WATER_TEMP_DP),
+		HeatingCapacityPercent: int(num(INVERTER_DPS_HEATING_CAPACITY_PERCENT_DP)),
+		Fault:                  int(num(INVERTER_DPS_FAULT_DP)),
+		// paradoxically, the silence mode is on when the DPS is false
+		SilenceMode: !flag(INVERTER_DPS_SILENCE_MODE_DP),
+	}
+	state.RawMode, err = dps.String(INVERTER_DPS_MODE_DP)
+	errs = append(errs, err)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	state.Mode = InverterHeatPumpMode(state.RawMode)
+	if !state.Mode.IsKnown() {
+		state.Mode = UNKNOWN
+	}
+	state.Faults = DecodeInverterHeatPumpFaults(state.Fault)
+	return state, nil
+}
+
+// getFloat reads a single numeric DPS.
+func (d *InverterHeatPumpDevice) getFloat(id string) (float64, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return 0, err
+	}
+	return dps.Float(id)
+}
+
+// GetInletWaterTemp returns the inlet water temperature.
+func (d *InverterHeatPumpDevice) GetInletWaterTemp() (float64, error) {
+	return d.getFloat(INVERTER_DPS_INLET_WATER_TEMP_DP)
+}
+
+// GetTargetWaterTemp returns the target water temperature.
+func (d *InverterHeatPumpDevice) GetTargetWaterTemp() (float64, error) {
+	return d.getFloat(INVERTER_DPS_TARGET_WATER_TEMP_DP)
+}
+
+// GetLowerLimitTargetWaterTemp returns the lower limit of the target water
+// temperature.
+func (d *InverterHeatPumpDevice) GetLowerLimitTargetWaterTemp() (float64, error) {
+	return d.getFloat(INVERTER_DPS_LOWER_LIMIT_TARGET_WATER_TEMP_DP)
+}
+
+// GetUpperLimitTargetWaterTemp returns the upper limit of the target water
+// temperature.
+func (d *InverterHeatPumpDevice) GetUpperLimitTargetWaterTemp() (float64, error) {
+	return d.getFloat(INVERTER_DPS_UPPER_LIMIT_TARGET_WATER_TEMP_DP)
+}
+
+// GetHeatingCapacityPercent returns the heating capacity in percent.
+func (d *InverterHeatPumpDevice) GetHeatingCapacityPercent() (int, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return 0, err
+	}
+	return dps.Int(INVERTER_DPS_HEATING_CAPACITY_PERCENT_DP)
+}
+
+// GetMode returns the mode, or UNKNOWN if the device reports an
+// undocumented mode. State has the raw value.
+func (d *InverterHeatPumpDevice) GetMode() (InverterHeatPumpMode, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return UNKNOWN, err
+	}
+	raw, err := dps.String(INVERTER_DPS_MODE_DP)
+	if err != nil {
+		return UNKNOWN, err
+	}
+	mode := InverterHeatPumpMode(raw)
+	if !mode.IsKnown() {
+		return UNKNOWN, nil
+	}
+	return mode, nil
+}
+
+// GetFault returns the fault, or UNKNOWN_FAULT if the device reports an
+// undocumented fault code. GetFaults decodes combined faults.
+func (d *InverterHeatPumpDevice) GetFault() (InverterHeatPumpFault, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return UNKNOWN_FAULT, err
+	}
+	raw, err := dps.Int(INVERTER_DPS_FAULT_DP)
+	if err != nil {
+		return UNKNOWN_FAULT, err
+	}
+	fault := InverterHeatPumpFault(raw)
+	if !fault.IsKnown() {
+		return UNKNOWN_FAULT, nil
+	}
+	return fault, nil
+}
+
+// GetFaults returns the active faults decoded from the fault bitmap, or nil
+// when the heat pump is nominal.
+func (d *InverterHeatPumpDevice) GetFaults() ([]InverterHeatPumpFault, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	bitmap, err := dps.Int(INVERTER_DPS_FAULT_DP)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeInverterHeatPumpFaults(bitmap), nil
+}
+
+// IsSilenceMode returns True if the silence mode is on.
+func (d *InverterHeatPumpDevice) IsSilenceMode() (bool, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return false, err
+	}
+	silence, err := dps.Bool(INVERTER_DPS_SILENCE_MODE_DP)
+	// paradoxically, the silence mode is on when the DPS is false
+	return !silence, err
+}
+
+// SetUnit sets the unit of the temperature.
+func (d *InverterHeatPumpDevice) SetUnit(unit TemperatureUnit) (map[string]interface{}, error) {
+	return d.SetValue(103, bool(unit))
+}
+
+// SetTargetWaterTemp sets the target water temperature, which must be within
+// the limits reported by the device.
 func (d *InverterHeatPumpDevice) SetTargetWaterTemp(target float64) (map[string]interface{}, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	lower, err := dps.Float(INVERTER_DPS_LOWER_LIMIT_TARGET_WATER_TEMP_DP)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := dps.Float(INVERTER_DPS_UPPER_LIMIT_TARGET_WATER_TEMP_DP)
+	if err != nil {
+		return nil, err
+	}
+	if target < lower || target > upper {
+		return nil, fmt.Errorf("target water temperature must be between %v and %v", lower, upper)
+	}
 	return d.SetValue(106, target)
 }
+
+// GetCurrentTemperature returns the inlet water temperature.
+func (d *InverterHeatPumpDevice) GetCurrentTemperature() (float64, error) {
+	return d.GetInletWaterTemp()
+}
+
+// GetTargetTemperature returns the target water temperature.
+func (d *InverterHeatPumpDevice) GetTargetTemperature() (float64, error) {
+	return d.GetTargetWaterTemp()
+}
+
+// SetTargetTemperature sets the target water temperature, like
+// SetTargetWaterTemp.
+func (d *InverterHeatPumpDevice) SetTargetTemperature(t float64) (map[string]interface{}, error) {
+	return d.SetTargetWaterTemp(t)
+}
+
+// SetSilenceMode turns the silence mode on or off.
+func (d *InverterHeatPumpDevice) SetSilenceMode(on bool) (map[string]interface{}, error) {
+	// paradoxically, the silence mode is on when the DPS is false
+	return d.SetValue(117, !on)
+}
@@ -1,10 +1,13 @@
 package contrib
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"time"
 
+	"tinytuya_go/contrib/ircodec"
 	"tinytuya_go/core"
 )
 
@@ -14,50 +17,200 @@ const (
 	IR_DP_LEARNED_ID     = "202"
 )
 
+// DPS used by control type 2 devices.
+const (
+	IR_DP_MODE           = "1"
+	IR_DP_LEARNED_REPORT = "2"
+	IR_DP_HEAD           = "3"
+	IR_DP_KEY_CODE       = "4"
+	IR_DP_KEY_CODE2      = "5"
+	IR_DP_KEY_CODE3      = "6"
+	IR_DP_KEY_STUDY      = "7"
+	IR_DP_KEY_STUDY2     = "8"
+	IR_DP_KEY_STUDY3     = "9"
+	IR_DP_SEND_DELAY     = "10"
+	IR_DP_KEY_CODE4      = "11"
+	IR_DP_KEY_STUDY4     = "12"
+	IR_DP_CODE_TYPE      = "13"
+)
+
+// IR control types.
+const (
+	IR_CONTROL_TYPE_DETECT = 0 // detect on creation
+	IR_CONTROL_TYPE_1      = 1 // older devices using DPS 201/202
+	IR_CONTROL_TYPE_2      = 2 // newer devices using DPS 1-13
+)
+
 // IRRemoteControlDevice represents a Tuya WiFi smart universal remote control simulator.
 type IRRemoteControlDevice struct {
 	*core.Device
 	controlType int
 }
 
-// NewIRRemoteControlDevice creates a new IRRemoteControlDevice.
-func NewIRRemoteControlDevice(d *core.Device) *IRRemoteControlDevice {
-	return &IRRemoteControlDevice{Device: d}
+// NewIRRemoteControlDevice creates a new IRRemoteControlDevice. A controlType
+// of IR_CONTROL_TYPE_DETECT connects to the device to detect it.
+func NewIRRemoteControlDevice(d *core.Device, controlType int) (*IRRemoteControlDevice, error) {
+	ir := &IRRemoteControlDevice{Device: d, controlType: controlType}
+	if controlType == IR_CONTROL_TYPE_DETECT {
+		if _, err := ir.DetectControlType(); err != nil {
+			return nil, err
+		}
+	}
+	return ir, nil
+}
+
+// ControlType returns the control type in use, IR_CONTROL_TYPE_DETECT until
+// it has been detected.
+func (d *IRRemoteControlDevice) ControlType() int {
+	return d.controlType
+}
+
+// resolveControlType returns the control type, detecting it first if it is
+// not known yet.
+func (d *IRRemoteControlDevice) resolveControlType() (int, error) {
+	if d.controlType != IR_CONTROL_TYPE_DETECT {
+		return d.controlType, nil
+	}
+	return d.DetectControlType()
+}
+
+// DetectControlType polls the device status to detect the control type.
+func (d *IRRemoteControlDevice) DetectControlType() (int, error) {
+	// Neither device type responds to status() after a reboot until a command
+	// is sent, so send study_exit in both formats first
+	oldTimeout := d.ConnectionTimeout
+	d.ConnectionTimeout = time.Second
+	defer func() { d.ConnectionTimeout = oldTimeout }()
+
+	for _, t := range []int{IR_CONTROL_TYPE_1, IR_CONTROL_TYPE_2} {
+		d.controlType = t
+		if _, err := d.StudyEnd(); err != nil {
+			d.controlType = IR_CONTROL_TYPE_DETECT
+			return 0, err
+		}
+	}
+	d.controlType = IR_CONTROL_TYPE_DETECT
+
+	status, err := d.Status()
+	if err != nil {
+		return 0, err
+	}
+	for status != nil {
+		if dps, err := core.DPSFromStatus(status); err == nil {
+			if dps.Has(IR_DP_SEND_IR) {
+				d.controlType = IR_CONTROL_TYPE_1
+			} else if dps.Has(IR_DP_MODE) {
+				d.controlType = IR_CONTROL_TYPE_2
+			}
+		}
+		resp, err := d.Receive(time.Second)
+		if err != nil {
+			break
+		}
+		status = resp.Result
+	}
+
+	if d.controlType == IR_CONTROL_TYPE_DETECT {
+		return 0, fmt.Errorf("detect control type failed, set the control type manually")
+	}
+	return d.controlType, nil
+}
+
+// IRKeyOptions are optional fields sent along with a head/key1 pair.
+type IRKeyOptions struct {
+	Delay int // delay in milliseconds, as found in the debug log
+	Type  int // identifier of the IR library
 }
 
-// SendCommand sends a command to the device.
+// SendCommand sends a command to the device. The "send" mode takes either a
+// "base64_code" or a "head" and "key" string in data.
 func (d *IRRemoteControlDevice) SendCommand(mode string, data map[string]interface{}) (map[string]interface{}, error) {
 	if mode == "send" {
-		var command map[string]interface{}
-		if d.controlType == 1 {
-			command = map[string]interface{}{
-				"control": "send_ir",
-				"type":    0,
-			}
-			if base64Code, ok := data["base64_code"]; ok {
-				command["head"] = ""
-				command["key1"] = "1" + base64Code.(string)
-			} else if head, ok := data["head"]; ok {
-				command["head"] = head
-				command["key1"] = "0" + data["key"].(string)
-			}
-			jsonData, _ := json.Marshal(command)
-			return d.SetValue(201, string(jsonData))
-		} else if d.controlType == 2 {
-			// Not implemented
-			return nil, fmt.Errorf("controlType 2 not implemented")
+		if base64Code, ok := data["base64_code"].(string); ok {
+			return d.SendButton(base64Code)
 		}
-	} else if d.controlType == 1 {
-		command := map[string]interface{}{"control": mode}
-		jsonData, _ := json.Marshal(command)
-		return d.SetValue(201, string(jsonData))
-	} else if d.controlType == 2 {
-		// Not implemented
-		return nil, fmt.Errorf("controlType 2 not implemented")
+		head, hasHead := data["head"].(string)
+		key, hasKey := data["key"].(string)
+		if !hasHead || !hasKey {
+			return nil, fmt.Errorf("send requires base64_code or head and key strings")
+		}
+		return d.SendKey(head, key, nil)
+	}
+
+	controlType, err := d.resolveControlType()
+	if err != nil {
+		return nil, err
+	}
+	switch controlType {
+	case IR_CONTROL_TYPE_1:
+		return nil, d.sendControl(map[string]interface{}{"control": mode})
+	case IR_CONTROL_TYPE_2:
+		return nil, d.Send(core.CONTROL, map[string]interface{}{IR_DP_MODE: mode})
 	}
 	return nil, fmt.Errorf("invalid mode or controlType")
 }
 
+// sendControl sends a JSON command in DPS 201 without waiting for a reply.
+func (d *IRRemoteControlDevice) sendControl(command map[string]interface{}) error {
+	jsonData, err := json.Marshal(command)
+	if err != nil {
+		return err
+	}
+	return d.Send(core.CONTROL, map[string]interface{}{IR_DP_SEND_IR: string(jsonData)})
+}
+
+// SendKey sends a head and key1 pair, such as those found in the Tuya debug
+// log. If it does not work, try removing a leading zero from key: depending
+// on the DPS set the device uses, key1 from the log may have an extra 0. Use
+// SendCode to send codes in other formats.
+func (d *IRRemoteControlDevice) SendKey(head, key string, opts *IRKeyOptions) (map[string]interface{}, error) {
+	if head == "" {
+		return nil, fmt.Errorf("head must not be empty")
+	}
+	if _, err := hex.DecodeString(head); err != nil || len(head) < 18 {
+		return nil, fmt.Errorf("head must be a hex string of at least 18 characters")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("key must not be empty")
+	}
+	if opts == nil {
+		opts = &IRKeyOptions{}
+	}
+	if opts.Delay < 0 {
+		return nil, fmt.Errorf("delay must not be negative")
+	}
+
+	controlType, err := d.resolveControlType()
+	if err != nil {
+		return nil, err
+	}
+	switch controlType {
+	case IR_CONTROL_TYPE_1:
+		command := map[string]interface{}{
+			"control": IR_CMD_SEND_KEY_CODE,
+			"head":    head,
+			"key1":    "0" + key,
+			"type":    opts.Type,
+		}
+		if opts.Delay > 0 {
+			command["delay"] = opts.Delay
+		}
+		return nil, d.sendControl(command)
+	case IR_CONTROL_TYPE_2:
+		command := map[string]interface{}{
+			IR_DP_MODE:      IR_CMD_SEND_KEY_CODE,
+			IR_DP_HEAD:      head,
+			IR_DP_KEY_CODE:  key,
+			IR_DP_CODE_TYPE: opts.Type,
+		}
+		if opts.Delay > 0 {
+			command[IR_DP_SEND_DELAY] = opts.Delay
+		}
+		return nil, d.Send(core.CONTROL, command)
+	}
+	return nil, fmt.Errorf("invalid controlType")
+}
+
 // StudyStart starts a study session.
 func (d *IRRemoteControlDevice) StudyStart() (map[string]interface{}, error) {
 	return d.SendCommand("study", nil)
@@ -68,8 +221,141 @@ func (d *IRRemoteControlDevice) StudyEnd() (map[string]interface{}, error) {
 	return d.SendCommand("study_exit", nil)
 }
 
+// ReceiveButton starts a study session and waits until a button is pressed on
+// a real remote, returning its learned code in Base64. Study mode is always
+// left afterwards. The wait is bounded by ctx.
+func (d *IRRemoteControlDevice) ReceiveButton(ctx context.Context) (string, error) {
+	// Exit study mode in case it's enabled
+	if _, err := d.StudyEnd(); err != nil {
+		return "", err
+	}
+	if _, err := d.StudyStart(); err != nil {
+		return "", err
+	}
+	defer d.StudyEnd()
 
-// SendButton simulates a learned button press.
+	id, value, err := d.WaitForDPS(ctx, IR_DP_LEARNED_ID, IR_DP_LEARNED_REPORT)
+	if err != nil {
+		return "", err
+	}
+	code, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected learned code in DPS %s: %v", id, value)
+	}
+	return code, nil
+}
+
+// SendButton simulates a learned button press. Use SendCode to send codes in
+// other formats.
 func (d *IRRemoteControlDevice) SendButton(base64Code string) (map[string]interface{}, error) {
-	return d.SendCommand("send", map[string]interface{}{"base64_code": base64Code})
+	if base64Code == "" {
+		return nil, fmt.Errorf("base64 code must not be empty")
+	}
+
+	controlType, err := d.resolveControlType()
+	if err != nil {
+		return nil, err
+	}
+	switch controlType {
+	case IR_CONTROL_TYPE_1:
+		return nil, d.sendControl(map[string]interface{}{
+			"control": IR_CMD_SEND_KEY_CODE,
+			"head":    "",
+			"key1":    "1" + base64Code,
+			"type":    0,
+		})
+	case IR_CONTROL_TYPE_2:
+		return nil, d.Send(core.CONTROL, map[string]interface{}{
+			IR_DP_MODE:      "study_key",
+			IR_DP_KEY_STUDY: base64Code,
+			IR_DP_CODE_TYPE: 0,
+		})
+	}
+	return nil, fmt.Errorf("invalid controlType")
+}
+
+// IRAddressCode is an address and command of a protocol such as NEC.
+type IRAddressCode struct {
+	Address int
+	Data    int
+}
+
+// IRCode is a code to send with SendCode. Exactly one format must be set.
+type IRCode struct {
+	Base64  string // learned Base64 code
+	Hex     string // little-endian 16-bit times
+	Pulses  []int  // pulse and gap times in microseconds
+	Pronto  string // raw Pronto code
+	NEC     *IRAddressCode
+	Samsung *IRAddressCode
+	Head    string // head and key1 pair, as found in the Tuya debug log
+	Key     string
+
+	// AsHeadKey sends a code given in any other format as a head and key1
+	// pair instead of a learned code.
+	AsHeadKey bool
+	// KeyOptions are used when sending a head and key1 pair.
+	KeyOptions *IRKeyOptions
+}
+
+// SendCode sends a code in any of the IRCode formats, converting it with the
+// ircodec package as needed.
+func (d *IRRemoteControlDevice) SendCode(code IRCode) (map[string]interface{}, error) {
+	set := 0
+	for _, ok := range []bool{
+		code.Base64 != "", code.Hex != "", len(code.Pulses) > 0, code.Pronto != "",
+		code.NEC != nil, code.Samsung != nil, code.Head != "" || code.Key != "",
+	} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("exactly one IR code format must be set, got %d", set)
+	}
+
+	if code.Head != "" || code.Key != "" {
+		return d.SendKey(code.Head, code.Key, code.KeyOptions)
+	}
+	if code.Base64 != "" && !code.AsHeadKey {
+		return d.SendButton(code.Base64)
+	}
+	if code.Pronto != "" && code.AsHeadKey {
+		// keep the carrier frequency of the Pronto code
+		head, key, err := ircodec.ProntoToHeadKey(code.Pronto)
+		if err != nil {
+			return nil, err
+		}
+		return d.SendKey(head, key, code.KeyOptions)
+	}
+
+	pulses := code.Pulses
+	var err error
+	switch {
+	case code.Base64 != "":
+		pulses, err = ircodec.Base64ToPulses(code.Base64)
+	case code.Hex != "":
+		pulses, err = ircodec.HexToPulses(code.Hex)
+	case code.Pronto != "":
+		pulses, err = ircodec.ProntoToPulses(code.Pronto)
+	case code.NEC != nil:
+		pulses = ircodec.NECToPulses(code.NEC.Address, code.NEC.Data)
+	case code.Samsung != nil:
+		pulses = ircodec.SamsungToPulses(code.Samsung.Address, code.Samsung.Data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(pulses) == 0 {
+		return nil, fmt.Errorf("no pulses to send")
+	}
+
+	if code.AsHeadKey {
+		head, key, err := ircodec.PulsesToHeadKey(pulses, 0.1, 38)
+		if err != nil {
+			return nil, err
+		}
+		return d.SendKey(head, key, code.KeyOptions)
+	}
+	return d.SendButton(ircodec.PulsesToBase64(pulses))
 }
@@ -0,0 +1,411 @@
+package ircodec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// timeCount is a pulse or gap time and how often it occurs.
+type timeCount struct {
+	time  int
+	count int
+}
+
+// symbolEntry maps a pattern letter to its time and assigned key1 symbol.
+type symbolEntry struct {
+	letter byte
+	time   int
+	symbol byte
+}
+
+// symbolList is an insertion-ordered list of pattern letters.
+type symbolList []*symbolEntry
+
+func (l symbolList) get(letter byte) *symbolEntry {
+	for _, e := range l {
+		if e.letter == letter {
+			return e
+		}
+	}
+	return nil
+}
+
+func (l symbolList) symbols(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		b.WriteByte(l.get(pattern[i]).symbol)
+	}
+	return b.String()
+}
+
+// letterMap assigns letters to times in order of first appearance.
+type letterMap struct {
+	first  byte
+	times  []int
+	counts map[int]int
+}
+
+func newLetterMap(first byte) *letterMap {
+	return &letterMap{first: first, counts: make(map[int]int)}
+}
+
+func (m *letterMap) add(t int) (letter byte, isNew bool) {
+	for i, seen := range m.times {
+		if seen == t {
+			m.counts[t]++
+			return m.first + byte(i), false
+		}
+	}
+	m.times = append(m.times, t)
+	m.counts[t] = 1
+	return m.first + byte(len(m.times)-1), true
+}
+
+// mostCommon returns the letter of the most frequent time, the first one
+// seen winning ties.
+func (m *letterMap) mostCommon() byte {
+	best, bestCount := 0, 0
+	for i, t := range m.times {
+		if m.counts[t] > bestCount {
+			best, bestCount = i, m.counts[t]
+		}
+	}
+	return m.first + byte(best)
+}
+
+// mergeSimilarPulseTimes repeatedly merges times within fudge of each other
+// into their average, returning a map from original to merged time.
+func mergeSimilarPulseTimes(counts []timeCount, fudge float64) map[int]int {
+	merged := make(map[int]int)
+	for {
+		ia, ib := -1, -1
+	search:
+		for i, cur := range counts {
+			pfudge := float64(cur.time) * fudge
+			pmin := float64(cur.time) - pfudge
+			pmax := float64(cur.time) + pfudge
+			for j, check := range counts {
+				if cur.time == check.time {
+					continue
+				}
+				if float64(check.time) >= pmin && float64(check.time) <= pmax {
+					ia, ib = i, j
+					break search
+				}
+			}
+		}
+		if ia < 0 {
+			return merged
+		}
+
+		a, b := counts[ia].time, counts[ib].time
+		newCount := counts[ia].count + counts[ib].count
+		newTime := round(float64(a+b) / 2)
+
+		var remaining []timeCount
+		for _, c := range counts {
+			if c.time != a && c.time != b {
+				remaining = append(remaining, c)
+			}
+		}
+		counts = remaining
+		found := false
+		for i := range counts {
+			if counts[i].time == newTime {
+				counts[i].count = newCount
+				found = true
+			}
+		}
+		if !found {
+			counts = append(counts, timeCount{newTime, newCount})
+		}
+
+		merged[a] = newTime
+		merged[b] = newTime
+		for k, v := range merged {
+			if v == a || v == b {
+				merged[k] = newTime
+			}
+		}
+	}
+}
+
+// PulsesToHeadKey converts pulse and gap times into a head and key1 pair.
+// Times within fudge (a fraction, usually 0.1) of each other are merged, and
+// the shorter of the pulse-width and space-width encodings is returned. freq
+// is the carrier frequency in kHz.
+func PulsesToHeadKey(pulses []int, fudge, freq float64) (string, string, error) {
+	if len(pulses) < 2 {
+		return "", "", fmt.Errorf("at least 2 pulses are required")
+	}
+	if len(pulses)%2 == 1 {
+		pulses = append(append([]int(nil), pulses...), pulses[0])
+	}
+
+	var counts []timeCount
+	for _, p := range pulses {
+		found := false
+		for i := range counts {
+			if counts[i].time == p {
+				counts[i].count++
+				found = true
+				break
+			}
+		}
+		if !found {
+			counts = append(counts, timeCount{p, 1})
+		}
+	}
+	merged := mergeSimilarPulseTimes(counts, fudge)
+
+	// Convert the pulse and space lengths into a string to make it easier to
+	// group and count unique sequences. The first unique pulse gets the
+	// letter 'A' and the first space 'a', all of the same length sharing a
+	// letter. I.e. [4523 4523 552 1683 552 1683 552 552 552 552] becomes
+	// AaBbBbBcBc, and we can then count 'Bb' and 'Bc'.
+	var pattern strings.Builder
+	var symbols symbolList
+	pulseLetters := newLetterMap('A')
+	spaceLetters := newLetterMap('a')
+	for i, p := range pulses {
+		if m, ok := merged[p]; ok {
+			p = m
+		}
+		letters := pulseLetters
+		if i%2 == 1 {
+			letters = spaceLetters
+		}
+		letter, isNew := letters.add(p)
+		if isNew && symbols.get(letter) == nil {
+			symbols = append(symbols, &symbolEntry{letter: letter, time: p})
+		}
+		pattern.WriteByte(letter)
+	}
+	symbolPattern := pattern.String()
+
+	letterOf := [2]byte{pulseLetters.mostCommon(), spaceLetters.mostCommon()}
+
+	var shortest [2]string
+	var encodingSymbols [2]map[byte]int
+	var bitTimeTypes [2]int
+	var zeroSymbol, oneSymbol string
+
+	// Calculate the head and key for both pulse-width and space-width
+	// encoding, the shorter of the two is used
+	for encodingType := 0; encodingType < 2; encodingType++ {
+		current := letterOf[encodingType]
+
+		var pairs []string
+		pairCounts := make(map[string]int)
+		for i := encodingType; i+1 < len(symbolPattern); i += 2 {
+			if symbolPattern[i] != current {
+				continue
+			}
+			k := symbolPattern[i : i+2]
+			if pairCounts[k] == 0 {
+				pairs = append(pairs, k)
+			}
+			pairCounts[k]++
+		}
+
+		// find the most-common and next-most-common pattern pair
+		maxCount, nextCount := 0, 0
+		var maxPair, nextPair string
+		for _, k := range pairs {
+			if pairCounts[k] > maxCount {
+				maxCount, maxPair = pairCounts[k], k
+			}
+		}
+		for _, k := range pairs {
+			if k != maxPair && pairCounts[k] > nextCount {
+				nextCount, nextPair = pairCounts[k], k
+			}
+		}
+
+		for _, e := range symbols {
+			e.symbol = 0
+		}
+
+		tryBitfield := true
+		skipFirst := false
+		fullPattern := symbolPattern
+
+		if maxCount > 0 && nextCount == 0 {
+			symbols.get(maxPair[0]).symbol = '@'
+			symbols.get(maxPair[1]).symbol = '#'
+			zeroSymbol = maxPair
+			oneSymbol = ""
+		} else if maxCount > 0 && nextCount > 0 {
+			// assign timing symbols to the most-common and next-most-common lengths
+			a, b := symbols.get(maxPair[0]), symbols.get(nextPair[0])
+			if a.time == b.time {
+				// pulses are the same, it might be space-width encoded
+				a.symbol, b.symbol = '@', '@'
+				a2, b2 := symbols.get(maxPair[1]), symbols.get(nextPair[1])
+				if a2.time < b2.time {
+					a2.symbol, b2.symbol = '#', '$'
+					zeroSymbol, oneSymbol = maxPair, nextPair
+				} else {
+					a2.symbol, b2.symbol = '$', '#'
+					zeroSymbol, oneSymbol = nextPair, maxPair
+				}
+			} else {
+				// pulses are not the same
+				if a.time < b.time {
+					a.symbol, b.symbol = '#', '$'
+					zeroSymbol, oneSymbol = maxPair, nextPair
+				} else {
+					a.symbol, b.symbol = '$', '#'
+					zeroSymbol, oneSymbol = nextPair, maxPair
+				}
+
+				a2, b2 := symbols.get(maxPair[1]), symbols.get(nextPair[1])
+				if a2.time == b2.time {
+					// but all spaces are the same, probably pulse-width encoded
+					a2.symbol, b2.symbol = '@', '@'
+					skipFirst = true
+					fullPattern = symbolPattern[1:]
+				} else {
+					a2.symbol = '@'
+					tryBitfield = false
+				}
+			}
+		}
+
+		// If the common length and the zero length are the same, combine
+		// them as head type 1, otherwise use head type 2
+		var startEntry, zeroEntry *symbolEntry
+		for _, e := range symbols {
+			if e.symbol == '@' {
+				startEntry = e
+			} else if e.symbol == '#' {
+				zeroEntry = e
+			}
+		}
+		if startEntry != nil && zeroEntry != nil && startEntry.time == zeroEntry.time {
+			bitTimeTypes[encodingType] = 1
+			zeroEntry.symbol = '@'
+			for _, e := range symbols {
+				if e.symbol == '$' {
+					e.symbol = '#'
+				}
+			}
+		} else {
+			bitTimeTypes[encodingType] = 2
+		}
+
+		// assign symbols to the remaining pulse/space times, the common/0/1
+		// symbols were already set above
+		available := keySymbols[2:]
+		timeSymbols := make(map[int]byte)
+		for _, e := range symbols {
+			if e.symbol != 0 {
+				timeSymbols[e.time] = e.symbol
+			}
+		}
+		abort := false
+		for _, e := range symbols {
+			if e.symbol != 0 {
+				continue
+			}
+			if s, ok := timeSymbols[e.time]; ok {
+				e.symbol = s
+				continue
+			}
+			if available == "" {
+				// too many unique pulse/space values
+				abort = true
+				break
+			}
+			e.symbol = available[0]
+			available = available[1:]
+			timeSymbols[e.time] = e.symbol
+		}
+		if abort {
+			continue
+		}
+
+		rawPattern := symbols.symbols(symbolPattern)
+		bitPattern := rawPattern
+
+		// see if we can condense bitfields into len+data
+		if tryBitfield {
+			var out strings.Builder
+			if skipFirst {
+				out.WriteString(symbols.symbols(symbolPattern[:1]))
+			}
+			if encodingType == 1 {
+				out.WriteString(symbols.symbols(fullPattern[:1]))
+			}
+
+			bits, data := 0, 0
+			var byts []int
+			removed := ""
+			// the len+2 is to make sure we catch any trailing bits
+			for i := encodingType; i < len(fullPattern)+2; i += 2 {
+				k := fullPattern[min(i, len(fullPattern)):min(i+2, len(fullPattern))]
+				kSymbols := symbols.symbols(k)
+				isZero := len(k) == 2 && k == zeroSymbol
+				isOne := len(k) == 2 && k == oneSymbol
+				if isZero || isOne {
+					removed += kSymbols
+					bits++
+					if isOne {
+						data |= 1 << (8 - bits)
+					}
+					if bits == 8 {
+						byts = append(byts, data)
+						bits, data = 0, 0
+					}
+					continue
+				}
+				if bits > 0 || len(byts) > 0 {
+					// if the new bitfield is longer than the original timing
+					// symbols, don't use it
+					if bitfield := buildKeyBitfield(bits, data, byts); len(bitfield) < len(removed) {
+						out.WriteString(bitfield)
+					} else {
+						out.WriteString(removed)
+					}
+				}
+				bits, data = 0, 0
+				byts = nil
+				removed = ""
+				out.WriteString(kSymbols)
+			}
+
+			bitPattern = out.String()
+			if len(bitPattern) > len(rawPattern) {
+				bitPattern = rawPattern
+			}
+		}
+
+		shortest[encodingType] = bitPattern
+		encodingSymbols[encodingType] = make(map[byte]int)
+		for _, e := range symbols {
+			encodingSymbols[encodingType][e.symbol] = e.time
+		}
+	}
+
+	best := 0
+	if shortest[0] == "" || (shortest[1] != "" && len(shortest[1]) < len(shortest[0])) {
+		best = 1
+	}
+	if shortest[best] == "" {
+		return "", "", fmt.Errorf("cannot convert pulses to head/key, too many unique pulse/space values")
+	}
+
+	// copy over the symbol times in symbol order
+	var timings []int
+	for i := 0; i < len(keySymbols); i++ {
+		if t, ok := encodingSymbols[best][keySymbols[i]]; ok {
+			timings = append(timings, t)
+		} else if len(timings) < 3 {
+			timings = append(timings, 100)
+		} else {
+			break
+		}
+	}
+
+	return BuildHead(freq, bitTimeTypes[best], timings), "01" + shortest[best], nil
+}
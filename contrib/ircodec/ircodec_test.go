@@ -0,0 +1,169 @@
+package ircodec
+
+import (
+	"reflect"
+	"testing"
+)
+
+// pulsesClose reports whether got matches want within 5% per time.
+func pulsesClose(got, want []int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		diff := got[i] - want[i]
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff*20 > want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkNEC checks that pulses decode to a single valid NEC code.
+func checkNEC(t *testing.T, pulses []int, address, data int) {
+	t.Helper()
+	codes, err := PulsesToNEC(pulses)
+	if err != nil {
+		t.Fatalf("PulsesToNEC: %v", err)
+	}
+	if len(codes) != 1 {
+		t.Fatalf("PulsesToNEC: got %d codes, want 1", len(codes))
+	}
+	c := codes[0]
+	if !c.Valid || c.Address != address || c.Data != data {
+		t.Errorf("PulsesToNEC: got %+v, want address %#x data %#x", c, address, data)
+	}
+}
+
+func TestBase64RoundTrip(t *testing.T) {
+	pulses := NECToPulses(0x04, 0x08)
+	got, err := Base64ToPulses(PulsesToBase64(pulses))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, pulses) {
+		t.Errorf("got %v, want %v", got, pulses)
+	}
+
+	// learned codes may be padded with "1"
+	got, err = Base64ToPulses("1" + PulsesToBase64(pulses))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, pulses) {
+		t.Errorf("padded: got %v, want %v", got, pulses)
+	}
+}
+
+func TestHexRoundTrip(t *testing.T) {
+	pulses := []int{9000, 4500, 563, 1688, 563, 30000}
+	// times are stored little-endian, 9000 is 0x2328
+	code := PulsesToHex(pulses)
+	if want := "282394113302980633023075"; code != want {
+		t.Errorf("PulsesToHex: got %s, want %s", code, want)
+	}
+	got, err := HexToPulses(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, pulses) {
+		t.Errorf("got %v, want %v", got, pulses)
+	}
+}
+
+func TestNECRoundTrip(t *testing.T) {
+	for _, tc := range []struct{ address, data int }{
+		{0x00, 0x00},
+		{0x04, 0x08},
+		{0xFF, 0xA5},
+		{0x1234, 0x56},
+	} {
+		pulses := NECToPulses(tc.address, tc.data)
+		if len(pulses) != 68 {
+			t.Fatalf("NECToPulses(%#x, %#x): got %d times, want 68", tc.address, tc.data, len(pulses))
+		}
+		checkNEC(t, pulses, tc.address, tc.data)
+	}
+}
+
+func TestSamsungRoundTrip(t *testing.T) {
+	for _, tc := range []struct{ address, data int }{
+		{0x07, 0x02},
+		{0xE0, 0x40},
+	} {
+		codes, err := PulsesToSamsung(SamsungToPulses(tc.address, tc.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(codes) != 1 {
+			t.Fatalf("got %d codes, want 1", len(codes))
+		}
+		c := codes[0]
+		if !c.Valid || c.Address != tc.address || c.Data != tc.data {
+			t.Errorf("got %+v, want address %#x data %#x", c, tc.address, tc.data)
+		}
+	}
+}
+
+func TestProntoRoundTrip(t *testing.T) {
+	pulses := NECToPulses(0x04, 0x08)
+	pronto := PulsesToPronto(pulses)
+	if pronto[:19] != "0000 006D 0000 0022" {
+		t.Errorf("PulsesToPronto: unexpected preamble in %s", pronto)
+	}
+	got, err := ProntoToPulses(pronto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pulsesClose(got, pulses) {
+		t.Errorf("got %v, want about %v", got, pulses)
+	}
+	checkNEC(t, got, 0x04, 0x08)
+
+	if _, err := ProntoToPulses("0100 006D 0000 0001 0015 0015"); err == nil {
+		t.Error("ProntoToPulses accepted a non-raw code")
+	}
+	if _, err := ProntoToPulses("0000 006D 0000 0002 0015 0015"); err == nil {
+		t.Error("ProntoToPulses accepted a truncated code")
+	}
+}
+
+func TestHeadKeyRoundTrip(t *testing.T) {
+	pulses := NECToPulses(0x04, 0x08)
+	head, key, err := PulsesToHeadKey(pulses, 0.1, 38)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := HeadKeyToPulses(head, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pulsesClose(got, pulses) {
+		t.Errorf("got %v, want about %v", got, pulses)
+	}
+	checkNEC(t, got, 0x04, 0x08)
+
+	// an empty head means the key is a learned Base64 code
+	got, err = HeadKeyToPulses("", PulsesToBase64(pulses))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, pulses) {
+		t.Errorf("empty head: got %v, want %v", got, pulses)
+	}
+}
+
+func TestProntoToHeadKey(t *testing.T) {
+	head, key, err := ProntoToHeadKey(PulsesToPronto(NECToPulses(0x04, 0x08)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := HeadKeyToPulses(head, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNEC(t, got, 0x04, 0x08)
+}
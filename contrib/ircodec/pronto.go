@@ -0,0 +1,83 @@
+package ircodec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// prontoClock is the Pronto clock period in microseconds.
+const prontoClock = 0.241246
+
+// parsePronto parses a raw (learned) Pronto code into its timebase and the
+// burst pair times in microseconds.
+func parsePronto(pronto string) (int, []int, error) {
+	fields := strings.Fields(pronto)
+	words := make([]int, len(fields))
+	for i, f := range fields {
+		w, err := strconv.ParseUint(f, 16, 16)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid pronto word %q", f)
+		}
+		words[i] = int(w)
+	}
+	if len(words) < 4 {
+		return 0, nil, fmt.Errorf("pronto code is too short")
+	}
+	// only raw (learned) codes are handled
+	if words[0] != 0 {
+		return 0, nil, fmt.Errorf("unhandled pronto type: %04X", words[0])
+	}
+	timebase := words[1]
+	if timebase == 0 {
+		return 0, nil, fmt.Errorf("pronto code has no frequency")
+	}
+	pairs := words[2] + words[3]
+	words = words[4:]
+	if len(words) < pairs*2 {
+		return 0, nil, fmt.Errorf("pronto code is truncated")
+	}
+
+	scale := float64(timebase) * prontoClock
+	pulses := make([]int, pairs*2)
+	for i := range pulses {
+		pulses[i] = round(float64(words[i]) * scale)
+	}
+	return timebase, pulses, nil
+}
+
+// ProntoToPulses converts a raw Pronto code into pulse and gap times. Only
+// carrier frequencies around 38 kHz are supported.
+func ProntoToPulses(pronto string) ([]int, error) {
+	timebase, pulses, err := parsePronto(pronto)
+	if err != nil {
+		return nil, err
+	}
+	if timebase < 90 || timebase > 139 {
+		return nil, fmt.Errorf("unsupported pronto frequency")
+	}
+	return pulses, nil
+}
+
+// PulsesToPronto converts pulse and gap times into a raw 38 kHz Pronto code.
+func PulsesToPronto(pulses []int) string {
+	const freq = 38000.0
+	scale := 1 / freq * 1000000.0
+	ret := fmt.Sprintf("%04X %04X %04X %04X", 0, round(scale/prontoClock), 0, len(pulses)>>1)
+	for _, p := range pulses {
+		ret += fmt.Sprintf(" %04X", round(float64(p)/scale))
+	}
+	return ret
+}
+
+// ProntoToHeadKey converts a raw Pronto code into a head and key1 pair,
+// keeping its carrier frequency.
+func ProntoToHeadKey(pronto string) (string, string, error) {
+	timebase, pulses, err := parsePronto(pronto)
+	if err != nil {
+		return "", "", err
+	}
+	// 4,145,152 is 32,768 * 506 / 4
+	freq := float64(round(4145152.0/float64(timebase)/100)) / 10
+	return PulsesToHeadKey(pulses, 0.1, freq)
+}
@@ -0,0 +1,247 @@
+package ircodec
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// keySymbols are the timing symbols used in key1 codes, in head order.
+const keySymbols = "@#$%^&*()QWRLTXKVNM{}[]JUP<>|=HS~"
+
+// Base64ToPulses decodes a learned Base64 code into pulse and gap times in
+// microseconds.
+func Base64ToPulses(code string) ([]int, error) {
+	// code can be padded with "1"
+	if len(code)%4 == 1 && strings.HasPrefix(code, "1") {
+		code = code[1:]
+	}
+	raw, err := base64.StdEncoding.DecodeString(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 code: %w", err)
+	}
+	return bytesToPulses(raw), nil
+}
+
+// PulsesToBase64 encodes pulse and gap times into a learned Base64 code.
+func PulsesToBase64(pulses []int) string {
+	return base64.StdEncoding.EncodeToString(pulsesToBytes(pulses))
+}
+
+// HexToPulses decodes a hex string of little-endian 16-bit times into pulses.
+func HexToPulses(code string) ([]int, error) {
+	raw, err := hex.DecodeString(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex code: %w", err)
+	}
+	return bytesToPulses(raw), nil
+}
+
+// PulsesToHex encodes pulses as a hex string of little-endian 16-bit times.
+func PulsesToHex(pulses []int) string {
+	return hex.EncodeToString(pulsesToBytes(pulses))
+}
+
+func bytesToPulses(raw []byte) []int {
+	pulses := make([]int, len(raw)/2)
+	for i := range pulses {
+		pulses[i] = int(binary.LittleEndian.Uint16(raw[i*2:]))
+	}
+	return pulses
+}
+
+func pulsesToBytes(pulses []int) []byte {
+	raw := make([]byte, len(pulses)*2)
+	for i, p := range pulses {
+		binary.LittleEndian.PutUint16(raw[i*2:], uint16(p))
+	}
+	return raw
+}
+
+// round rounds half to even, matching the Python implementation.
+func round(f float64) int {
+	return int(math.RoundToEven(f))
+}
+
+// BuildHead builds a head string for the given carrier frequency in kHz and
+// bit time type. The first three timings are the bit, zero and one times,
+// followed by any extra symbol times, all in microseconds.
+func BuildHead(freq float64, bitTimeType int, timings []int) string {
+	scale := round(freq * 100)
+	timeBase := 100000.0 / float64(scale)
+
+	converted := make([]int, 3, len(timings)+3)
+	for i, t := range timings {
+		if i < 3 {
+			converted[i] = round(float64(t) / timeBase)
+		} else {
+			converted = append(converted, round(float64(t)/timeBase))
+		}
+	}
+
+	head := fmt.Sprintf("%02X%04X0000000000%02X", bitTimeType, scale, len(converted))
+	for _, t := range converted {
+		head += fmt.Sprintf("%04X", t)
+	}
+	return head
+}
+
+// HeadKeyToPulses expands a head and key1 pair into pulse and gap times. An
+// empty head means key is a learned Base64 code.
+func HeadKeyToPulses(head, key string) ([]int, error) {
+	if len(key) < 4 {
+		return nil, fmt.Errorf("key must be at least 4 characters")
+	}
+	if head == "" {
+		return Base64ToPulses(key)
+	}
+	if len(head) < 18 {
+		return nil, fmt.Errorf("head must be at least 18 characters")
+	}
+
+	rawHead, err := hex.DecodeString(head)
+	if err != nil {
+		return nil, fmt.Errorf("invalid head: %w", err)
+	}
+	headType := rawHead[0]
+	timeScale := binary.BigEndian.Uint16(rawHead[1:])
+	numTimings := int(binary.BigEndian.Uint16(rawHead[7:]))
+	if timeScale == 0 {
+		return nil, fmt.Errorf("head has no frequency")
+	}
+	if numTimings > len(keySymbols) {
+		return nil, fmt.Errorf("head has too many timings: %d", numTimings)
+	}
+	if len(rawHead) != numTimings*2+9 {
+		return nil, fmt.Errorf("head must be %d characters", (numTimings*2+9)*2)
+	}
+	if _, err := hexByte(key[:2]); err != nil {
+		return nil, fmt.Errorf("first 2 digits of key must be a hexadecimal byte")
+	}
+	key = key[2:]
+
+	// head type 1 uses '@' for 0 and '#' for 1
+	// head type 2 uses '#' for 0 and '$' for 1
+	var bitTimings [2]string
+	switch headType {
+	case 1:
+		bitTimings = [2]string{"@@", "@#"}
+	case 2:
+		bitTimings = [2]string{"@#", "@$"}
+	default:
+		return nil, fmt.Errorf("unhandled head type: %d", headType)
+	}
+
+	symbols := keySymbols[:numTimings]
+	timeBase := 100000.0 / float64(timeScale)
+	symbolTimings := make(map[byte]int, numTimings)
+	for i := 0; i < numTimings; i++ {
+		t := binary.BigEndian.Uint16(rawHead[9+i*2:])
+		symbolTimings[symbols[i]] = round(timeBase * float64(t))
+	}
+
+	// Unpack the packed bits into their symbol pairs first, then expand the
+	// symbols to their times
+	var expanded strings.Builder
+	for key != "" {
+		// copy symbols as-is
+		cnt := 0
+		for cnt < len(key) && strings.IndexByte(symbols, key[cnt]) >= 0 {
+			expanded.WriteByte(key[cnt])
+			cnt++
+		}
+		key = key[cnt:]
+		if key == "" {
+			break
+		}
+
+		// expand packed bits
+		if len(key) < 4 {
+			return nil, fmt.Errorf("truncated bitfield in key")
+		}
+		byts, err1 := hexByte(key[:2])
+		bits, err2 := hexByte(key[2:4])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid bitfield in key: %q", key[:4])
+		}
+		key = key[4:]
+
+		var data string
+		if byts != 0 {
+			// read in and transmit bytes until a symbol is encountered
+			cnt = 0
+			for cnt < len(key) && isHexDigit(key[cnt]) {
+				cnt++
+			}
+			data = key[:cnt]
+			bits = cnt * 4
+			if len(data)%2 == 1 {
+				data += "0"
+			}
+		} else {
+			// the next byte is how many bits to transmit
+			cnt = (bits + 7) / 8 * 2
+			if cnt > len(key) {
+				return nil, fmt.Errorf("truncated bitfield in key")
+			}
+			data = key[:cnt]
+		}
+		key = key[cnt:]
+
+		raw, err := hex.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bitfield in key: %w", err)
+		}
+		// devices transmit MSB first
+		for _, d := range raw {
+			for i := 0; i < 8 && bits > 0; i++ {
+				if d&0x80 == 0x80 {
+					expanded.WriteString(bitTimings[1])
+				} else {
+					expanded.WriteString(bitTimings[0])
+				}
+				d <<= 1
+				bits--
+			}
+		}
+	}
+
+	s := expanded.String()
+	pulses := make([]int, len(s))
+	for i := 0; i < len(s); i++ {
+		t, ok := symbolTimings[s[i]]
+		if !ok {
+			return nil, fmt.Errorf("head has no timing for symbol %q", s[i])
+		}
+		pulses[i] = t
+	}
+	return pulses, nil
+}
+
+func hexByte(s string) (int, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	return int(b[0]), nil
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'A' && c <= 'F') || (c >= 'a' && c <= 'f')
+}
+
+// buildKeyBitfield encodes bits as a key1 bitfield: a zero byte, the number
+// of bits, then the data bytes.
+func buildKeyBitfield(bits, bitData int, byts []int) string {
+	s := fmt.Sprintf("%02X%02X", 0, bits+len(byts)*8)
+	for _, b := range byts {
+		s += fmt.Sprintf("%02X", b)
+	}
+	if bits > 0 {
+		s += fmt.Sprintf("%02X", bitData)
+	}
+	return s
+}
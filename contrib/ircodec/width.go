@@ -0,0 +1,232 @@
+package ircodec
+
+import "fmt"
+
+// WidthEncoding holds the timings, in microseconds, of a 32-bit
+// width-encoded code.
+type WidthEncoding struct {
+	StartMark     int
+	StartSpace    int
+	PulseOne      int
+	PulseZero     int
+	SpaceOne      int
+	SpaceZero     int
+	TrailingPulse int
+	TrailingSpace int
+}
+
+// NECEncoding is the NEC protocol timing, the default for width-encoded codes.
+var NECEncoding = WidthEncoding{
+	StartMark:     9000,
+	StartSpace:    4500,
+	PulseOne:      563,
+	PulseZero:     563,
+	SpaceOne:      1688,
+	SpaceZero:     563,
+	TrailingPulse: 563,
+	TrailingSpace: 30000,
+}
+
+// SamsungEncoding is the Samsung protocol timing.
+var SamsungEncoding = WidthEncoding{
+	StartMark:     4500,
+	StartSpace:    4500,
+	PulseOne:      563,
+	PulseZero:     563,
+	SpaceOne:      1688,
+	SpaceZero:     563,
+	TrailingPulse: 563,
+	TrailingSpace: 30000,
+}
+
+// WidthDecoding configures PulsesToWidthEncoded. A zero field is not checked.
+// At least one of PulseThreshold and SpaceThreshold must be set; times at or
+// above a threshold are a 1 bit.
+type WidthDecoding struct {
+	StartMark      int
+	StartSpace     int
+	PulseThreshold int
+	SpaceThreshold int
+}
+
+// DecodedCode is a 32-bit code decoded from pulses. Valid reports whether the
+// address and data passed the protocol's complement checks.
+type DecodedCode struct {
+	Type    string
+	Uint32  uint32
+	Address int
+	Data    int
+	Valid   bool
+}
+
+// Hex returns the raw code as 8 hex digits.
+func (c DecodedCode) Hex() string {
+	return fmt.Sprintf("%08X", c.Uint32)
+}
+
+// WidthEncodedToPulses encodes a 32-bit value MSB first into pulses.
+func WidthEncodedToPulses(code uint32, enc WidthEncoding) []int {
+	pulses := make([]int, 0, 68)
+	pulses = append(pulses, enc.StartMark, enc.StartSpace)
+	for i := 31; i >= 0; i-- {
+		if code&(1<<i) != 0 {
+			pulses = append(pulses, enc.PulseOne, enc.SpaceOne)
+		} else {
+			pulses = append(pulses, enc.PulseZero, enc.SpaceZero)
+		}
+	}
+	return append(pulses, enc.TrailingPulse, enc.TrailingSpace)
+}
+
+// withinQuarter reports whether t is within 25% of want.
+func withinQuarter(t, want int) bool {
+	return float64(t) >= float64(want)*0.75 && float64(t) <= float64(want)*1.25
+}
+
+// PulsesToWidthEncoded decodes every distinct 32-bit code in pulses. Each code
+// is 68 times long: a start mark and space, 32 bits, and a trailing pulse and
+// space.
+func PulsesToWidthEncoded(pulses []int, dec WidthDecoding) ([]uint32, error) {
+	var ret []uint32
+	if len(pulses) < 68 {
+		return ret, fmt.Errorf("pulses must be at least 68 long (2 start + 64 data + 2 trailing)")
+	}
+	if dec.PulseThreshold == 0 && dec.SpaceThreshold == 0 {
+		return ret, fmt.Errorf("pulse threshold and/or space threshold must be supplied")
+	}
+
+	if dec.StartMark != 0 {
+		for len(pulses) >= 68 && !withinQuarter(pulses[0], dec.StartMark) {
+			pulses = pulses[1:]
+		}
+	}
+
+	for len(pulses) >= 68 {
+		if dec.StartMark != 0 && !withinQuarter(pulses[0], dec.StartMark) {
+			return ret, fmt.Errorf("the start mark is not the correct length")
+		}
+		if dec.StartSpace != 0 && !withinQuarter(pulses[1], dec.StartSpace) {
+			return ret, fmt.Errorf("the start space is not the correct length")
+		}
+		pulses = pulses[2:]
+
+		var code uint32
+		for i := 31; i >= 0; i-- {
+			pulseBit, spaceBit := -1, -1
+			if dec.PulseThreshold != 0 {
+				pulseBit = boolBit(pulses[0] >= dec.PulseThreshold)
+			}
+			if dec.SpaceThreshold != 0 {
+				spaceBit = boolBit(pulses[1] >= dec.SpaceThreshold)
+			}
+
+			bit := pulseBit
+			if bit < 0 {
+				bit = spaceBit
+			} else if spaceBit >= 0 && spaceBit != pulseBit {
+				return ret, fmt.Errorf("pulse and space thresholds conflict on bit %d", i)
+			}
+			code |= uint32(bit) << i
+			pulses = pulses[2:]
+		}
+		// trailing pulse and space
+		pulses = pulses[2:]
+
+		if !containsCode(ret, code) {
+			ret = append(ret, code)
+		}
+	}
+	return ret, nil
+}
+
+func boolBit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func containsCode(codes []uint32, code uint32) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// mirrorBits reverses the order of the low 8 bits.
+func mirrorBits(data int) int {
+	out := 0
+	for i := 0; i < 8; i++ {
+		if data&(1<<i) != 0 {
+			out |= 1 << (7 - i)
+		}
+	}
+	return out
+}
+
+// NECToPulses encodes an NEC address and command into pulses. An address
+// below 256 is sent as 8 bits followed by its complement, otherwise as 16
+// bits. Use WidthEncodedToPulses with NECEncoding to send a raw 32-bit code.
+func NECToPulses(address, data int) []int {
+	if address < 256 {
+		address = mirrorBits(address)
+		address = address<<8 | (address ^ 0xFF)
+	} else {
+		address = mirrorBits(address>>8&0xFF)<<8 | mirrorBits(address&0xFF)
+	}
+	data = mirrorBits(data)
+	data = data<<8 | (data ^ 0xFF)
+	return WidthEncodedToPulses(uint32(address<<16|data), NECEncoding)
+}
+
+// PulsesToNEC decodes the NEC codes found in pulses.
+func PulsesToNEC(pulses []int) ([]DecodedCode, error) {
+	codes, err := PulsesToWidthEncoded(pulses, WidthDecoding{StartMark: 9000, SpaceThreshold: 1125})
+	ret := make([]DecodedCode, 0, len(codes))
+	for _, code := range codes {
+		addr := mirrorBits(int(code >> 24 & 0xFF))
+		addrNot := mirrorBits(int(code >> 16 & 0xFF))
+		data := mirrorBits(int(code >> 8 & 0xFF))
+		dataNot := mirrorBits(int(code & 0xFF))
+		// an 8-bit address is repeated after complementing, just like the data
+		if addr != (addrNot ^ 0xFF) {
+			addr = addr<<8 | addrNot
+		}
+		d := DecodedCode{Type: "nec", Uint32: code}
+		if data == (dataNot ^ 0xFF) {
+			d.Address, d.Data, d.Valid = addr, data, true
+		}
+		ret = append(ret, d)
+	}
+	return ret, err
+}
+
+// SamsungToPulses encodes a Samsung address and command into pulses. Use
+// WidthEncodedToPulses with SamsungEncoding to send a raw 32-bit code.
+func SamsungToPulses(address, data int) []int {
+	address = mirrorBits(address)
+	data = mirrorBits(data)
+	code := uint32(address<<24 + address<<16 + data<<8 + (data ^ 0xFF))
+	return WidthEncodedToPulses(code, SamsungEncoding)
+}
+
+// PulsesToSamsung decodes the Samsung codes found in pulses.
+func PulsesToSamsung(pulses []int) ([]DecodedCode, error) {
+	codes, err := PulsesToWidthEncoded(pulses, WidthDecoding{StartMark: 4500, SpaceThreshold: 1125})
+	ret := make([]DecodedCode, 0, len(codes))
+	for _, code := range codes {
+		addr := int(code >> 24 & 0xFF)
+		addrNot := int(code >> 16 & 0xFF)
+		data := int(code >> 8 & 0xFF)
+		dataNot := int(code & 0xFF)
+		d := DecodedCode{Type: "samsung", Uint32: code}
+		// samsung repeats the 8-bit address but complements the 8-bit data
+		if addr == addrNot && data == (dataNot^0xFF) {
+			d.Address, d.Data, d.Valid = mirrorBits(addr), mirrorBits(data), true
+		}
+		ret = append(ret, d)
+	}
+	return ret, err
+}
@@ -1,39 +1,105 @@
 package contrib
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	"tinytuya_go/core"
 )
 
 const (
-	PRESENCE_DPS_KEY                  = "dps"
-	PRESENCE_DPS_PRESENCE_KEY          = "1"
-	PRESENCE_DPS_SENSITIVITY_KEY       = "2"
-	PRESENCE_DPS_NEAR_DETECTION_KEY    = "3"
-	PRESENCE_DPS_FAR_DETECTION_KEY     = "4"
+	PRESENCE_DPS_KEY                    = "dps"
+	PRESENCE_DPS_PRESENCE_KEY           = "1"
+	PRESENCE_DPS_SENSITIVITY_KEY        = "2"
+	PRESENCE_DPS_NEAR_DETECTION_KEY     = "3"
+	PRESENCE_DPS_FAR_DETECTION_KEY      = "4"
 	PRESENCE_DPS_AUTO_DETECT_RESULT_KEY = "6"
-	PRESENCE_DPS_TARGET_DISTANCE_KEY   = "9"
-	PRESENCE_DPS_DETECTION_DELAY_KEY   = "101"
-	PRESENCE_DPS_FADING_TIME_KEY       = "102"
-	PRESENCE_DPS_LIGHT_SENSE_KEY       = "104"
+	PRESENCE_DPS_TARGET_DISTANCE_KEY    = "9"
+	PRESENCE_DPS_DETECTION_DELAY_KEY    = "101"
+	PRESENCE_DPS_FADING_TIME_KEY        = "102"
+	PRESENCE_DPS_LIGHT_SENSE_KEY        = "104"
 )
 
+// Presence states reported in DPS 1.
+const (
+	PRESENCE_STATE_PRESENCE = "presence"
+	PRESENCE_STATE_NONE     = "none"
+)
+
+const (
+	// the device sometimes answers without the presence DPS, so the status
+	// is requested again a few times
+	presenceStatusRetries    = 5
+	presenceStatusRetryDelay = 5 * time.Second
+	// presenceHeartbeatInterval keeps the connection open while watching
+	presenceHeartbeatInterval = 10 * time.Second
+)
+
+// PresenceConfig is the detection configuration, in raw device units.
+type PresenceConfig struct {
+	Sensitivity    int // 0-9
+	NearDetection  int // minimum detection distance
+	FarDetection   int // maximum detection distance
+	DetectionDelay int
+	FadingTime     int // time without presence before reporting none
+}
+
+// PresenceEvent is a change of presence or target distance.
+type PresenceEvent struct {
+	Time            time.Time
+	Presence        string // PRESENCE_STATE_*
+	TargetDistance  int
+	PresenceChanged bool
+	DistanceChanged bool
+}
+
+// Present reports whether presence is detected.
+func (e PresenceEvent) Present() bool {
+	return e.Presence == PRESENCE_STATE_PRESENCE
+}
+
 // PresenceDetectorDevice represents a Tuya-based Presence Detector.
 type PresenceDetectorDevice struct {
 	*core.Device
 }
 
+// presenceStatus returns the DPS of the device status, retrying when the
+// presence DPS is missing until ctx is done.
+func (d *PresenceDetectorDevice) presenceStatus(ctx context.Context) (core.DPS, error) {
+	for retry := 0; ; retry++ {
+		dps, err := d.DPS()
+		if err != nil {
+			return nil, err
+		}
+		if dps.Has(PRESENCE_DPS_PRESENCE_KEY) || retry == presenceStatusRetries {
+			return dps, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(presenceStatusRetryDelay):
+		}
+	}
+}
+
+// presenceInt returns a numeric DPS from the device status.
+func (d *PresenceDetectorDevice) presenceInt(id string) (int, error) {
+	dps, err := d.presenceStatus(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	return dps.Int(id)
+}
+
 // StatusJSON returns a JSON string of the device status with human-readable labels.
 func (d *PresenceDetectorDevice) StatusJSON() (string, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
 	if err != nil {
 		return "", err
 	}
-	dps, ok := status[PRESENCE_DPS_KEY].(map[string]interface{})
-	if !ok {
-		return "", nil
-	}
 
 	jsonBytes, err := json.Marshal(map[string]interface{}{
 		"Presence":        dps[PRESENCE_DPS_PRESENCE_KEY],
@@ -54,10 +120,180 @@ func (d *PresenceDetectorDevice) StatusJSON() (string, error) {
 
 // GetPresenceState returns the presence state of the Presence Detector.
 func (d *PresenceDetectorDevice) GetPresenceState() (string, error) {
-	status, err := d.Status()
+	dps, err := d.presenceStatus(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return dps.String(PRESENCE_DPS_PRESENCE_KEY)
+}
+
+// GetSensitivity returns the sensitivity level, 0 to 9.
+func (d *PresenceDetectorDevice) GetSensitivity() (int, error) {
+	return d.presenceInt(PRESENCE_DPS_SENSITIVITY_KEY)
+}
+
+// GetNearDetection returns the near detection distance.
+func (d *PresenceDetectorDevice) GetNearDetection() (int, error) {
+	return d.presenceInt(PRESENCE_DPS_NEAR_DETECTION_KEY)
+}
+
+// GetFarDetection returns the far detection distance.
+func (d *PresenceDetectorDevice) GetFarDetection() (int, error) {
+	return d.presenceInt(PRESENCE_DPS_FAR_DETECTION_KEY)
+}
+
+// GetCheckingResult returns the self check result, one of checking,
+// check_success, check_failure, others, comm_fault or radar_fault.
+func (d *PresenceDetectorDevice) GetCheckingResult() (string, error) {
+	dps, err := d.presenceStatus(context.Background())
 	if err != nil {
 		return "", err
 	}
-	state, _ := status[PRESENCE_DPS_KEY].(map[string]interface{})[PRESENCE_DPS_PRESENCE_KEY].(string)
-	return state, nil
+	return dps.String(PRESENCE_DPS_AUTO_DETECT_RESULT_KEY)
+}
+
+// GetTargetDistance returns the distance of the closest target.
+func (d *PresenceDetectorDevice) GetTargetDistance() (int, error) {
+	return d.presenceInt(PRESENCE_DPS_TARGET_DISTANCE_KEY)
+}
+
+// GetDetectionDelay returns the detection delay.
+func (d *PresenceDetectorDevice) GetDetectionDelay() (int, error) {
+	return d.presenceInt(PRESENCE_DPS_DETECTION_DELAY_KEY)
+}
+
+// GetFadingTime returns the fading time.
+func (d *PresenceDetectorDevice) GetFadingTime() (int, error) {
+	return d.presenceInt(PRESENCE_DPS_FADING_TIME_KEY)
+}
+
+// GetLightSense returns the light level.
+func (d *PresenceDetectorDevice) GetLightSense() (int, error) {
+	return d.presenceInt(PRESENCE_DPS_LIGHT_SENSE_KEY)
+}
+
+// Config returns the detection configuration.
+func (d *PresenceDetectorDevice) Config() (*PresenceConfig, error) {
+	dps, err := d.presenceStatus(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	num := func(id string) int {
+		v, err := dps.Int(id)
+		errs = append(errs, err)
+		return v
+	}
+	c := &PresenceConfig{
+		Sensitivity:    num(PRESENCE_DPS_SENSITIVITY_KEY),
+		NearDetection:  num(PRESENCE_DPS_NEAR_DETECTION_KEY),
+		FarDetection:   num(PRESENCE_DPS_FAR_DETECTION_KEY),
+		DetectionDelay: num(PRESENCE_DPS_DETECTION_DELAY_KEY),
+		FadingTime:     num(PRESENCE_DPS_FADING_TIME_KEY),
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetConfig validates and sets the whole detection configuration in a single
+// command.
+func (d *PresenceDetectorDevice) SetConfig(c PresenceConfig) (map[string]interface{}, error) {
+	if c.NearDetection > c.FarDetection {
+		return nil, fmt.Errorf("near detection must not be beyond far detection")
+	}
+	return d.setConfig(map[string]int{
+		PRESENCE_DPS_SENSITIVITY_KEY:     c.Sensitivity,
+		PRESENCE_DPS_NEAR_DETECTION_KEY:  c.NearDetection,
+		PRESENCE_DPS_FAR_DETECTION_KEY:   c.FarDetection,
+		PRESENCE_DPS_DETECTION_DELAY_KEY: c.DetectionDelay,
+		PRESENCE_DPS_FADING_TIME_KEY:     c.FadingTime,
+	})
+}
+
+// SetSensitivity sets the sensitivity level, 0 to 9.
+func (d *PresenceDetectorDevice) SetSensitivity(sensitivity int) (map[string]interface{}, error) {
+	return d.setConfig(map[string]int{PRESENCE_DPS_SENSITIVITY_KEY: sensitivity})
+}
+
+// SetNearDetection sets the near detection distance.
+func (d *PresenceDetectorDevice) SetNearDetection(distance int) (map[string]interface{}, error) {
+	return d.setConfig(map[string]int{PRESENCE_DPS_NEAR_DETECTION_KEY: distance})
+}
+
+// SetFarDetection sets the far detection distance.
+func (d *PresenceDetectorDevice) SetFarDetection(distance int) (map[string]interface{}, error) {
+	return d.setConfig(map[string]int{PRESENCE_DPS_FAR_DETECTION_KEY: distance})
+}
+
+// SetDetectionDelay sets the detection delay.
+func (d *PresenceDetectorDevice) SetDetectionDelay(delay int) (map[string]interface{}, error) {
+	return d.setConfig(map[string]int{PRESENCE_DPS_DETECTION_DELAY_KEY: delay})
+}
+
+// SetFadingTime sets the fading time.
+func (d *PresenceDetectorDevice) SetFadingTime(fading int) (map[string]interface{}, error) {
+	return d.setConfig(map[string]int{PRESENCE_DPS_FADING_TIME_KEY: fading})
+}
+
+// setConfig validates configuration values and sends them together. Values
+// are validated against the mapping when the device has one. Otherwise the
+// sensitivity must be 0 to 9, as documented for the Python module, and
+// distances and times must not be negative.
+func (d *PresenceDetectorDevice) setConfig(values map[string]int) (map[string]interface{}, error) {
+	b := d.NewBatch()
+	for id, v := range values {
+		if _, ok := d.Mapping[id]; !ok {
+			if id == PRESENCE_DPS_SENSITIVITY_KEY && (v < 0 || v > 9) {
+				b.AddError(fmt.Errorf("sensitivity must be between 0 and 9, got %d", v))
+				continue
+			}
+			if v < 0 {
+				b.AddError(fmt.Errorf("DPS %s must not be negative, got %d", id, v))
+				continue
+			}
+		}
+		b.Set(id, v)
+	}
+	return b.Send()
+}
+
+// WatchPresence calls fn with presence and target distance changes until ctx
+// is done or the connection fails, starting with the current state. Changes
+// are received as the device reports them, so the device should be created
+// with a persistent connection. Heartbeats keep the connection open.
+func (d *PresenceDetectorDevice) WatchPresence(ctx context.Context, fn func(PresenceEvent)) error {
+	dps, err := d.presenceStatus(ctx)
+	if err != nil {
+		return err
+	}
+	last := PresenceEvent{Time: time.Now(), PresenceChanged: true, DistanceChanged: true}
+	if last.Presence, err = dps.String(PRESENCE_DPS_PRESENCE_KEY); err != nil {
+		return err
+	}
+	if dps.Has(PRESENCE_DPS_TARGET_DISTANCE_KEY) {
+		if last.TargetDistance, err = dps.Int(PRESENCE_DPS_TARGET_DISTANCE_KEY); err != nil {
+			return err
+		}
+	}
+	fn(last)
+
+	return d.Watch(ctx, presenceHeartbeatInterval, func(report map[string]interface{}) {
+		// reports only carry the changed DPS, so absent ones are unchanged
+		dps := core.DPS(report)
+		ev := PresenceEvent{Time: time.Now(), Presence: last.Presence, TargetDistance: last.TargetDistance}
+		if v, err := dps.String(PRESENCE_DPS_PRESENCE_KEY); err == nil && v != last.Presence {
+			ev.Presence = v
+			ev.PresenceChanged = true
+		}
+		if v, err := dps.Int(PRESENCE_DPS_TARGET_DISTANCE_KEY); err == nil && v != last.TargetDistance {
+			ev.TargetDistance = v
+			ev.DistanceChanged = true
+		}
+		if ev.PresenceChanged || ev.DistanceChanged {
+			fn(ev)
+			last = ev
+		}
+	})
 }
@@ -0,0 +1,201 @@
+package contrib
+
+import (
+	"sync"
+
+	"tinytuya_go/core"
+)
+
+// DeviceMatcher describes the devices a contrib type handles. A device
+// matches on its product ID (or product key) or its category. Having every
+// DPS in the signature also matches on its own for signatures of at least
+// signatureMinDPS DPS; shorter signatures are too common and only tell apart
+// types which matched otherwise, such as light strips among bulbs.
+type DeviceMatcher struct {
+	ProductIDs []string
+	Categories []string
+	DPS        []string
+}
+
+// DeviceFactory wraps a device in a typed device.
+type DeviceFactory func(d *core.Device) (interface{}, error)
+
+// signatureMinDPS is the shortest DPS signature which matches on its own.
+const signatureMinDPS = 6
+
+type registration struct {
+	name    string
+	matcher DeviceMatcher
+	factory DeviceFactory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registration
+)
+
+// Register adds a typed device to the registry used by New. Registering a
+// name again replaces the earlier registration.
+func Register(name string, m DeviceMatcher, f DeviceFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for i := range registry {
+		if registry[i].name == name {
+			registry[i] = registration{name, m, f}
+			return
+		}
+	}
+	registry = append(registry, registration{name, m, f})
+}
+
+// score rates how specifically the matcher matches the device, 0 being no
+// match. A product ID beats a category, which beats a DPS signature; longer
+// signatures beat shorter ones.
+func (m DeviceMatcher) score(info *core.DeviceInfo, known map[string]bool) int {
+	score := 0
+	if info != nil {
+		if (info.ProductID != "" && containsString(m.ProductIDs, info.ProductID)) ||
+			(info.ProductKey != "" && containsString(m.ProductIDs, info.ProductKey)) {
+			score += 10000
+		}
+		if info.Category != "" && containsString(m.Categories, info.Category) {
+			score += 1000
+		}
+	}
+	if len(m.DPS) > 0 {
+		all := true
+		for _, id := range m.DPS {
+			if !known[id] {
+				all = false
+				break
+			}
+		}
+		if all && (score > 0 || len(m.DPS) >= signatureMinDPS) {
+			score += 10 * len(m.DPS)
+		}
+	}
+	return score
+}
+
+// Match returns the name of the registered type which matches the device
+// most specifically, or "" if none does. Devices are matched on their Info
+// and the DPS known from the mapping, Info and detected DPS, without
+// contacting the device.
+func Match(d *core.Device) string {
+	r, ok := bestMatch(d)
+	if !ok {
+		return ""
+	}
+	return r.name
+}
+
+func bestMatch(d *core.Device) (registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	known := d.KnownDPS()
+	var best registration
+	bestScore := 0
+	for _, r := range registry {
+		if s := r.matcher.score(d.Info, known); s > bestScore {
+			best, bestScore = r, s
+		}
+	}
+	return best, bestScore > 0
+}
+
+// New wraps a device in the registered type which matches it most
+// specifically, such as *SocketDevice. Unknown devices are returned as the
+// generic mapping-based *core.Device.
+func New(d *core.Device) (interface{}, error) {
+	r, ok := bestMatch(d)
+	if !ok {
+		return d, nil
+	}
+	return r.factory(d)
+}
+
+func init() {
+	Register("atorch_temperature_controller", DeviceMatcher{
+		DPS: []string{DPS_MODE, DPS_CUR_TEMP, DPS_SWITCH_STATE, DPS_CURRENT, DPS_POWER, DPS_VOLTAGE},
+	}, func(d *core.Device) (interface{}, error) {
+		return &AtorchTemperatureControllerDevice{Device: d}, nil
+	})
+	Register("blanket", DeviceMatcher{
+		Categories: []string{"dr"},
+		DPS:        []string{BLANKET_DPS_BODY_LEVEL, BLANKET_DPS_FEET_LEVEL, BLANKET_DPS_BODY_TIME, BLANKET_DPS_FEET_TIME},
+	}, func(d *core.Device) (interface{}, error) {
+		return &BlanketDevice{Device: d}, nil
+	})
+	Register("bulb", DeviceMatcher{
+		Categories: []string{"dj", "dd", "fwd", "xdd"},
+		DPS:        []string{"20", "21", "22"},
+	}, func(d *core.Device) (interface{}, error) {
+		return core.NewBulbDevice(d, "")
+	})
+	// DPS 1-5 and 19 are also a power strip with metering, so air
+	// conditioners are only matched on their category
+	Register("climate", DeviceMatcher{
+		Categories: []string{"kt"},
+	}, func(d *core.Device) (interface{}, error) {
+		return &ClimateDevice{Device: d}, nil
+	})
+	// light strips share the bulb category, the X7 DPS make it more specific
+	Register("colorful_x7", DeviceMatcher{
+		Categories: []string{"dd"},
+		DPS:        []string{COLORFULX7_DPS_INDEX_ON, COLORFULX7_DPS_INDEX_MODE, COLORFULX7_DPS_INDEX_WORKMODE, COLORFULX7_DPS_INDEX_DYNAMIC_MODE, COLORFULX7_DPS_INDEX_MUSIC_MODE},
+	}, func(d *core.Device) (interface{}, error) {
+		return &ColorfulX7Device{Device: d}, nil
+	})
+	Register("cover", DeviceMatcher{
+		Categories: []string{"cl", "clkg"},
+	}, func(d *core.Device) (interface{}, error) {
+		return core.NewCoverDevice(d), nil
+	})
+	Register("doorbell", DeviceMatcher{
+		Categories: []string{"sp"},
+		DPS:        []string{DOORBELL_DPS_ACTIVE, DOORBELL_DPS_VOLUME, DOORBELL_DPS_MOTION_AREA},
+	}, func(d *core.Device) (interface{}, error) {
+		return &DoorbellDevice{Device: d}, nil
+	})
+	Register("inverter_heat_pump", DeviceMatcher{
+		DPS: []string{INVERTER_DPS_INLET_WATER_TEMP_DP, INVERTER_DPS_UNIT_DP, INVERTER_DPS_HEATING_CAPACITY_PERCENT_DP, INVERTER_DPS_MODE_DP, INVERTER_DPS_TARGET_WATER_TEMP_DP, INVERTER_DPS_LOWER_LIMIT_TARGET_WATER_TEMP_DP, INVERTER_DPS_UPPER_LIMIT_TARGET_WATER_TEMP_DP},
+	}, func(d *core.Device) (interface{}, error) {
+		return &InverterHeatPumpDevice{Device: d}, nil
+	})
+	Register("ir_remote_control", DeviceMatcher{
+		Categories: []string{"wnykq"},
+	}, func(d *core.Device) (interface{}, error) {
+		// the control type is detected on first use, as New must not
+		// contact the device
+		return &IRRemoteControlDevice{Device: d, controlType: IR_CONTROL_TYPE_DETECT}, nil
+	})
+	Register("outlet", DeviceMatcher{
+		Categories: []string{"kg", "tdq", "pc"},
+	}, func(d *core.Device) (interface{}, error) {
+		return core.NewOutletDevice(d), nil
+	})
+	Register("presence_detector", DeviceMatcher{
+		Categories: []string{"hps"},
+		DPS:        []string{PRESENCE_DPS_PRESENCE_KEY, PRESENCE_DPS_SENSITIVITY_KEY, PRESENCE_DPS_NEAR_DETECTION_KEY, PRESENCE_DPS_FAR_DETECTION_KEY, PRESENCE_DPS_TARGET_DISTANCE_KEY},
+	}, func(d *core.Device) (interface{}, error) {
+		return &PresenceDetectorDevice{Device: d}, nil
+	})
+	Register("socket", DeviceMatcher{
+		Categories: []string{"cz"},
+		DPS:        []string{SOCKET_DPS_STATE, SOCKET_DPS_CURRENT, SOCKET_DPS_POWER, SOCKET_DPS_VOLTAGE},
+	}, func(d *core.Device) (interface{}, error) {
+		return &SocketDevice{Device: d}, nil
+	})
+	Register("thermostat", DeviceMatcher{
+		Categories: []string{"wk"},
+		DPS:        []string{THERMOSTAT_DPS_MODE, THERMOSTAT_DPS_TEMP_UNIT, THERMOSTAT_DPS_FAN, THERMOSTAT_DPS_HOLD},
+	}, func(d *core.Device) (interface{}, error) {
+		return NewThermostatDevice(d), nil
+	})
+	Register("wifi_dual_meter", DeviceMatcher{
+		Categories: []string{"zndb"},
+		DPS:        []string{WIFI_DUAL_METER_DPS_POWER_A, WIFI_DUAL_METER_DPS_DIR_CUR_A, WIFI_DUAL_METER_DPS_POWER_B, WIFI_DUAL_METER_DPS_VOLTAGE, WIFI_DUAL_METER_DPS_CURRENT_A, WIFI_DUAL_METER_DPS_CURRENT_B},
+	}, func(d *core.Device) (interface{}, error) {
+		return &WiFiDualMeterDevice{Device: d}, nil
+	})
+}
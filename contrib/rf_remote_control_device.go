@@ -1,7 +1,11 @@
 package contrib
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"tinytuya_go/core"
 )
@@ -11,10 +15,82 @@ type RFRemoteControlDevice struct {
 	*IRRemoteControlDevice
 }
 
-// NewRFRemoteControlDevice creates a new RFRemoteControlDevice.
-func NewRFRemoteControlDevice(d *core.Device) *RFRemoteControlDevice {
-	ir := NewIRRemoteControlDevice(d)
-	return &RFRemoteControlDevice{IRRemoteControlDevice: ir}
+// RFButton is a learned RF button decoded from its Base64 code, which
+// RFSendDecodedButton replays.
+type RFButton struct {
+	Code      string                 // learned Base64 code
+	Freq      string                 // study frequency in MHz, i.e. "433"
+	Ver       string                 // protocol version
+	RFType    string                 // usually "sub_2g"
+	Times     int                    // number of times the code is sent
+	Delay     int                    // delay before sending
+	Intervals int                    // interval between repeats
+	Raw       map[string]interface{} // all decoded fields
+}
+
+// RF_DEFAULT_TIMES is the number of times a learned code is sent by default.
+const RF_DEFAULT_TIMES = 6
+
+// NewRFRemoteControlDevice creates a new RFRemoteControlDevice. A controlType
+// of IR_CONTROL_TYPE_DETECT connects to the device to detect it.
+func NewRFRemoteControlDevice(d *core.Device, controlType int) (*RFRemoteControlDevice, error) {
+	ir, err := NewIRRemoteControlDevice(d, controlType)
+	if err != nil {
+		return nil, err
+	}
+	return &RFRemoteControlDevice{IRRemoteControlDevice: ir}, nil
+}
+
+// SendCommand sends a command to the device. Modes other than the RF study
+// and send modes are handled by IRRemoteControlDevice.
+func (d *RFRemoteControlDevice) SendCommand(mode string, data map[string]interface{}) (map[string]interface{}, error) {
+	switch mode {
+	case "rf_study", "rfstudy_exit", "rfstudy_send", "rf_shortstudy", "rfshortstudy_exit":
+		command := map[string]interface{}{
+			"control":   mode,
+			"rf_type":   rfDefault(data["rf_type"], "sub_2g"),
+			"study_feq": rfDefault(data["freq"], "0"),
+			"ver":       rfDefault(data["ver"], "2"),
+		}
+		if mode == "rfstudy_send" {
+			for i := 1; i < 10; i++ {
+				k := fmt.Sprintf("key%d", i)
+				if v, ok := data[k]; ok {
+					command[k] = v
+				}
+			}
+		}
+		return nil, d.sendControl(command)
+	case "send_cmd":
+		command := make(map[string]interface{}, len(data)+1)
+		for k, v := range data {
+			command[k] = v
+		}
+		command["control"] = mode
+		return nil, d.sendControl(command)
+	}
+	return d.IRRemoteControlDevice.SendCommand(mode, data)
+}
+
+// rfDefault returns v as a string, or def if it is missing or empty.
+func rfDefault(v interface{}, def string) string {
+	if s := rfString(v); s != "" {
+		return s
+	}
+	return def
+}
+
+// rfString formats a string or JSON number field as a string.
+func rfString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	}
+	return ""
 }
 
 // RFStudyStart starts an RF study session.
@@ -37,14 +113,98 @@ func (d *RFRemoteControlDevice) RFStudyEnd(freq int, short bool) (map[string]int
 	return d.SendCommand(cmd, data)
 }
 
-// RFSendButton sends a learned RF button press.
+// RFReceiveButton starts an RF study session and waits until a button is
+// pressed on a real remote, returning its learned code in Base64. A freq of 0
+// auto-detects the frequency. Study mode is always left afterwards. The wait
+// is bounded by ctx.
+func (d *RFRemoteControlDevice) RFReceiveButton(ctx context.Context, freq int) (string, error) {
+	// Exit study mode in case it's enabled
+	d.RFStudyEnd(0, false)
+	if _, err := d.RFStudyStart(freq, false); err != nil {
+		return "", err
+	}
+	defer d.RFStudyEnd(freq, false)
+
+	id, value, err := d.WaitForDPS(ctx, IR_DP_LEARNED_ID, IR_DP_LEARNED_REPORT)
+	if err != nil {
+		return "", err
+	}
+	code, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected learned code in DPS %s: %v", id, value)
+	}
+	return code, nil
+}
+
+// RFSendButton sends a learned RF button press. The study frequency and
+// version are taken from the learned code when present.
 func (d *RFRemoteControlDevice) RFSendButton(base64Code string, times, delay, intervals int) (map[string]interface{}, error) {
-	key1 := map[string]interface{}{
-		"code":      base64Code,
-		"times":     times,
-		"delay":     delay,
-		"intervals": intervals,
+	btn := RFButton{Code: base64Code}
+	if decoded, err := RFDecodeButton(base64Code); err == nil {
+		btn = *decoded
+	}
+	btn.Times, btn.Delay, btn.Intervals = times, delay, intervals
+	return d.RFSendDecodedButton(btn)
+}
+
+// RFSendDecodedButton sends a button, such as one returned by
+// RFDecodeButton, with its frequency, version and repeat settings.
+func (d *RFRemoteControlDevice) RFSendDecodedButton(btn RFButton) (map[string]interface{}, error) {
+	if btn.Code == "" {
+		return nil, fmt.Errorf("RF button has no code")
+	}
+	data := map[string]interface{}{
+		"key1": map[string]interface{}{
+			"code":      btn.Code,
+			"times":     btn.Times,
+			"delay":     btn.Delay,
+			"intervals": btn.Intervals,
+		},
+		"freq":    btn.Freq,
+		"ver":     btn.Ver,
+		"rf_type": btn.RFType,
 	}
-	data := map[string]interface{}{"key1": key1}
 	return d.SendCommand("rfstudy_send", data)
 }
+
+// RFPrintButton returns the JSON string from a learned button. The Base64
+// code is decoded but not JSON parsed.
+func RFPrintButton(base64Code string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(base64Code)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode learned button: %w", err)
+	}
+	return string(raw), nil
+}
+
+// RFDecodeButton decodes a learned button into its settings. Times defaults
+// to RF_DEFAULT_TIMES when the code does not set it.
+func RFDecodeButton(base64Code string) (*RFButton, error) {
+	jstr, err := RFPrintButton(base64Code)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(jstr), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse learned button: %w", err)
+	}
+
+	button := &RFButton{
+		Code:   base64Code,
+		Freq:   rfString(raw["study_feq"]),
+		Ver:    rfString(raw["ver"]),
+		RFType: rfString(raw["rf_type"]),
+		Times:  RF_DEFAULT_TIMES,
+		Raw:    raw,
+	}
+	if v, ok := raw["times"].(float64); ok {
+		button.Times = int(v)
+	}
+	if v, ok := raw["delay"].(float64); ok {
+		button.Delay = int(v)
+	}
+	if v, ok := raw["intervals"].(float64); ok {
+		button.Intervals = int(v)
+	}
+	return button, nil
+}
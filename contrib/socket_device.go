@@ -1,6 +1,8 @@
 package contrib
 
 import (
+	"time"
+
 	"tinytuya_go/core"
 )
 
@@ -16,51 +18,89 @@ type SocketDevice struct {
 	*core.Device
 }
 
+// socketMeterDPS are the metering DPS, which the device only reports on request.
+var socketMeterDPS = []int{18, 19, 20}
+
 // GetEnergyConsumption returns the energy consumption data.
 func (d *SocketDevice) GetEnergyConsumption() (map[string]interface{}, error) {
-	status, err := d.Status()
-	if err != nil {
-		return nil, err
-	}
-	return status["dps"].(map[string]interface{}), nil
+	return meterDPS(d.Device, socketMeterDPS...)
 }
 
 // GetCurrent returns the current in mA.
 func (d *SocketDevice) GetCurrent() (float64, error) {
-	status, err := d.Status()
+	dps, err := meterDPS(d.Device, socketMeterDPS...)
 	if err != nil {
 		return 0, err
 	}
-	current, _ := status["dps"].(map[string]interface{})[SOCKET_DPS_CURRENT].(float64)
-	return current, nil
+	return dps.Float(SOCKET_DPS_CURRENT)
 }
 
 // GetPower returns the power in W.
 func (d *SocketDevice) GetPower() (float64, error) {
-	status, err := d.Status()
+	dps, err := meterDPS(d.Device, socketMeterDPS...)
 	if err != nil {
 		return 0, err
 	}
-	power, _ := status["dps"].(map[string]interface{})[SOCKET_DPS_POWER].(float64)
-	return power / 10, nil
+	power, err := dps.Float(SOCKET_DPS_POWER)
+	return power / 10, err
 }
 
 // GetVoltage returns the voltage in V.
 func (d *SocketDevice) GetVoltage() (float64, error) {
-	status, err := d.Status()
+	dps, err := meterDPS(d.Device, socketMeterDPS...)
 	if err != nil {
 		return 0, err
 	}
-	voltage, _ := status["dps"].(map[string]interface{})[SOCKET_DPS_VOLTAGE].(float64)
-	return voltage / 10, nil
+	voltage, err := dps.Float(SOCKET_DPS_VOLTAGE)
+	return voltage / 10, err
 }
 
 // GetState returns the current state of the device.
 func (d *SocketDevice) GetState() (bool, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
 	if err != nil {
 		return false, err
 	}
-	on, _ := status["dps"].(map[string]interface{})[SOCKET_DPS_STATE].(bool)
-	return on, nil
+	return dps.Bool(SOCKET_DPS_STATE)
+}
+
+// IsOn returns the state of the device, like GetState.
+func (d *SocketDevice) IsOn() (bool, error) {
+	return d.GetState()
+}
+
+// SwitchOn turns on the socket.
+func (d *SocketDevice) SwitchOn() (map[string]interface{}, error) {
+	return d.SetValue(1, true)
+}
+
+// SwitchOff turns off the socket.
+func (d *SocketDevice) SwitchOff() (map[string]interface{}, error) {
+	return d.SetValue(1, false)
+}
+
+// EnergySample returns the power, voltage and current from a single poll.
+func (d *SocketDevice) EnergySample() (EnergySample, error) {
+	dps, err := meterDPS(d.Device, socketMeterDPS...)
+	if err != nil {
+		return EnergySample{}, err
+	}
+	current, err := dps.Float(SOCKET_DPS_CURRENT)
+	if err != nil {
+		return EnergySample{}, err
+	}
+	power, err := dps.Float(SOCKET_DPS_POWER)
+	if err != nil {
+		return EnergySample{}, err
+	}
+	voltage, err := dps.Float(SOCKET_DPS_VOLTAGE)
+	if err != nil {
+		return EnergySample{}, err
+	}
+	return EnergySample{
+		Time:    time.Now(),
+		Power:   power / 10,
+		Voltage: voltage / 10,
+		Current: current / 1000,
+	}, nil
 }
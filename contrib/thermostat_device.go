@@ -1,56 +1,619 @@
 package contrib
 
 import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
 	"tinytuya_go/core"
 )
 
 const (
 	THERMOSTAT_DPS_MODE             = "2"
 	THERMOSTAT_DPS_TEMP_SET         = "16"
+	THERMOSTAT_DPS_TEMP_SET_F       = "17"
+	THERMOSTAT_DPS_UPPER_TEMP_F_LOW = "18"
+	THERMOSTAT_DPS_UPPER_TEMP_LOW   = "19"
+	THERMOSTAT_DPS_LOWER_TEMP_F_LOW = "20"
+	THERMOSTAT_DPS_TEMP_UNIT        = "23"
 	THERMOSTAT_DPS_TEMP_CURRENT     = "24"
+	THERMOSTAT_DPS_LOWER_TEMP_LOW   = "26"
+	THERMOSTAT_DPS_TEMP_CORRECTION  = "27"
+	THERMOSTAT_DPS_TEMP_CURRENT_F   = "29"
+	THERMOSTAT_DPS_HUMIDITY         = "34"
+	THERMOSTAT_DPS_FAULT            = "45"
+	THERMOSTAT_DPS_SYSTEM_TYPE      = "107"
 	THERMOSTAT_DPS_UPPER_TEMP       = "108"
 	THERMOSTAT_DPS_LOWER_TEMP       = "109"
+	THERMOSTAT_DPS_UPPER_TEMP_F     = "110"
+	THERMOSTAT_DPS_LOWER_TEMP_F     = "111"
 	THERMOSTAT_DPS_FAN              = "115"
+	THERMOSTAT_DPS_HOME             = "116"
+	THERMOSTAT_DPS_SCHEDULE         = "118"
 	THERMOSTAT_DPS_SCHEDULE_ENABLED = "119"
 	THERMOSTAT_DPS_HOLD             = "120"
+	THERMOSTAT_DPS_VACATION         = "121"
+	THERMOSTAT_DPS_FAN_RUN_TIME     = "123"
+	THERMOSTAT_DPS_SYSTEM           = "129"
+	THERMOSTAT_DPS_WEATHER_FORECAST = "130"
+)
+
+// thermostatSensorDPS are the DPS holding remote sensor lists.
+var thermostatSensorDPS = []string{"122", "125", "126", "127", "128"}
+
+// Resolution of the setpoint DPS a thermostat uses. Low resolution devices
+// use DPS 18-20 and 26, high resolution devices DPS 108-111.
+const (
+	thermostatResAny = iota
+	thermostatResLow
+	thermostatResHigh
 )
 
+// thermostatDP describes a thermostat DPS: its name, an alternative name,
+// how its value is scaled and encoded, and the allowed values.
+type thermostatDP struct {
+	name   string
+	alt    string
+	scale  float64
+	enum   []string
+	base64 bool
+	res    int
+	decInt bool
+}
+
+// thermostatDPS are the known thermostat DPS, in DPS order.
+var thermostatDPS = []struct {
+	id string
+	thermostatDP
+}{
+	{"2", thermostatDP{name: "mode", enum: []string{"auto", "cool", "heat", "emergencyheat", "off"}}},
+	{"16", thermostatDP{name: "temp_set", alt: "setpoint_c", scale: 100}},
+	{"17", thermostatDP{name: "temp_set_f", alt: "setpoint_f"}},
+	{"18", thermostatDP{name: "upper_temp_f", alt: "cooling_setpoint_f", res: thermostatResLow}},
+	{"19", thermostatDP{name: "upper_temp", alt: "cooling_setpoint_c", res: thermostatResLow}},
+	{"20", thermostatDP{name: "lower_temp_f", alt: "heating_setpoint_f", res: thermostatResLow}},
+	{"23", thermostatDP{name: "temp_unit_convert", alt: "units", enum: []string{"f", "c"}}},
+	{"24", thermostatDP{name: "temp_current", alt: "temperature_c", scale: 100}},
+	{"26", thermostatDP{name: "lower_temp", alt: "heating_setpoint_c", res: thermostatResLow}},
+	{"27", thermostatDP{name: "temp_correction", res: thermostatResLow}},
+	{"29", thermostatDP{name: "temp_current_f", alt: "temperature_f"}},
+	{"34", thermostatDP{name: "humidity"}},
+	{"45", thermostatDP{name: "fault"}},
+	{"107", thermostatDP{name: "system_type", decInt: true}},
+	{"108", thermostatDP{name: "upper_temp", alt: "cooling_setpoint_c", scale: 100, res: thermostatResHigh}},
+	{"109", thermostatDP{name: "lower_temp", alt: "heating_setpoint_c", scale: 100, res: thermostatResHigh}},
+	{"110", thermostatDP{name: "upper_temp_f", alt: "cooling_setpoint_f", res: thermostatResHigh}},
+	{"111", thermostatDP{name: "lower_temp_f", alt: "heating_setpoint_f", res: thermostatResHigh}},
+	{"115", thermostatDP{name: "fan", enum: []string{"auto", "cycle", "on"}}},
+	{"116", thermostatDP{name: "home"}},
+	{"118", thermostatDP{name: "schedule", base64: true}},
+	{"119", thermostatDP{name: "schedule_enabled"}},
+	{"120", thermostatDP{name: "hold", enum: []string{"permhold", "temphold", "followschedule"}}},
+	{"121", thermostatDP{name: "vacation", base64: true}},
+	{"123", thermostatDP{name: "fan_run_time"}},
+	{"129", thermostatDP{name: "system", enum: []string{"fanon", "coolfanon", "alloff", "heatfanon", "heaton"}}},
+	{"130", thermostatDP{name: "weather_forcast"}},
+}
+
+// ThermostatState is the decoded state of a thermostat. Temperatures are in
+// degrees, setpoints in both C and F as the device reports both.
+type ThermostatState struct {
+	Mode             string // auto, cool, heat, emergencyheat or off
+	SetpointC        float64
+	SetpointF        float64
+	CoolingSetpointC float64
+	CoolingSetpointF float64
+	HeatingSetpointC float64
+	HeatingSetpointF float64
+	Units            string // c or f
+	TempCorrection   float64
+	TemperatureC     float64
+	TemperatureF     float64
+	Humidity         int
+	Fault            int    // fault flags, e1, e2, e3
+	SystemType       int    // 4 = heatpump, 5 = 2-stage heatpump?
+	Fan              string // auto, cycle or on
+	Home             interface{}
+	ScheduleEnabled  bool
+	Hold             string // permhold, temphold or followschedule
+	Vacation         []byte
+	FanRunTime       int    // minutes per hour the fan runs when circulating
+	System           string // fanon, coolfanon, alloff, heatfanon or heaton
+	WeatherForecast  interface{}
+}
+
+// ThermostatUpdate lists what changed in a status update.
+type ThermostatUpdate struct {
+	DPS            map[string]interface{}
+	Changed        []string // names of the changed values
+	ChangedSensors []*ThermostatSensor
+}
+
 // ThermostatDevice represents a Tuya based 24v Thermostat.
 type ThermostatDevice struct {
 	*core.Device
+	State       ThermostatState
+	Schedule    *ThermostatSchedule
+	SensorLists []*ThermostatSensorList
+
+	// highRes is thermostatResAny until a resolution-specific DPS is seen
+	highRes int
+	raw     map[string]interface{}
+	// pending queues changes between DelayUpdates and SendUpdates
+	pending *ThermostatBatch
+}
+
+// ThermostatBatch collects named thermostat changes, such as setpoint, mode
+// and fan, so they are validated and sent together in one command.
+type ThermostatBatch struct {
+	*core.Batch
+	d *ThermostatDevice
+}
+
+// NewBatch starts a batch of thermostat changes.
+func (d *ThermostatDevice) NewBatch() *ThermostatBatch {
+	return &ThermostatBatch{Batch: d.Device.NewBatch(), d: d}
+}
+
+// Set queues a named value, such as "mode" or "cooling_setpoint_c". Invalid
+// values are reported by Send.
+func (b *ThermostatBatch) Set(key string, value interface{}) *ThermostatBatch {
+	dps, v, err := b.d.ParseValue(key, value)
+	if err != nil {
+		b.AddError(err)
+		return b
+	}
+	b.Batch.Set(dps, v)
+	return b
+}
+
+// SetValues queues several named values.
+func (b *ThermostatBatch) SetValues(values map[string]interface{}) *ThermostatBatch {
+	for k, v := range values {
+		b.Set(k, v)
+	}
+	return b
 }
 
-// SetSetpoint sets the target temperature.
-func (d *ThermostatDevice) SetSetpoint(setpoint float64) (map[string]interface{}, error) {
-	return d.SetValue(16, setpoint)
+// DelayUpdates queues the changes made by the setters instead of sending
+// them, until SendUpdates is called.
+func (d *ThermostatDevice) DelayUpdates() {
+	if d.pending == nil {
+		d.pending = d.NewBatch()
+	}
 }
 
-// SetCoolSetpoint sets the cooling setpoint.
-func (d *ThermostatDevice) SetCoolSetpoint(setpoint float64) (map[string]interface{}, error) {
-	return d.SetValue(108, setpoint)
+// SendUpdates sends all changes queued since DelayUpdates in one command and
+// stops queueing.
+func (d *ThermostatDevice) SendUpdates() (map[string]interface{}, error) {
+	pending := d.pending
+	d.pending = nil
+	if pending == nil {
+		return nil, nil
+	}
+	return pending.Send()
 }
 
-// SetHeatSetpoint sets the heating setpoint.
-func (d *ThermostatDevice) SetHeatSetpoint(setpoint float64) (map[string]interface{}, error) {
-	return d.SetValue(109, setpoint)
+// SetValues sets several named values in one command, or queues them after
+// DelayUpdates.
+func (d *ThermostatDevice) SetValues(values map[string]interface{}) (map[string]interface{}, error) {
+	if d.pending != nil {
+		d.pending.SetValues(values)
+		return nil, d.pending.Err()
+	}
+	return d.NewBatch().SetValues(values).Send()
+}
+
+// NewThermostatDevice creates a new ThermostatDevice.
+func NewThermostatDevice(d *core.Device) *ThermostatDevice {
+	t := &ThermostatDevice{Device: d}
+	t.init()
+	return t
+}
+
+func (d *ThermostatDevice) init() {
+	if d.raw != nil {
+		return
+	}
+	d.raw = make(map[string]interface{})
+	for _, dps := range thermostatSensorDPS {
+		d.SensorLists = append(d.SensorLists, NewThermostatSensorList(dps))
+	}
+}
+
+// Sensors returns the remote sensors of all sensor lists.
+func (d *ThermostatDevice) Sensors() []*ThermostatSensor {
+	var sensors []*ThermostatSensor
+	for _, l := range d.SensorLists {
+		sensors = append(sensors, l.Sensors...)
+	}
+	return sensors
+}
+
+// FindSensor returns the sensor with the given hex ID or name.
+func (d *ThermostatDevice) FindSensor(name string) *ThermostatSensor {
+	for _, s := range d.Sensors() {
+		if s.IDString() == name || s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// GetCF parses cf into "c" or "f", returning the system units when cf is "".
+func (d *ThermostatDevice) GetCF(cf string) string {
+	if cf == "" {
+		cf = d.State.Units
+	}
+	if cf == "f" {
+		return "f"
+	}
+	return "c"
+}
+
+// IsSingleSetpoint reports whether the system expects a single setpoint, as
+// opposed to separate cooling and heating setpoints in auto mode.
+func (d *ThermostatDevice) IsSingleSetpoint() bool {
+	return d.State.Mode != "auto"
+}
+
+// SetSetpoint sets the cooling or heating setpoint according to the system
+// mode, letting the thermostat figure it out in other modes. An empty cf
+// uses the system units.
+func (d *ThermostatDevice) SetSetpoint(setpoint float64, cf string) (map[string]interface{}, error) {
+	switch d.State.Mode {
+	case "cool":
+		return d.SetCoolSetpoint(setpoint, cf)
+	case "heat", "emergencyheat":
+		return d.SetHeatSetpoint(setpoint, cf)
+	}
+	return d.SetMiddleSetpoint(setpoint, cf)
+}
+
+// GetCurrentTemperature refreshes the state and returns the temperature in
+// the system units.
+func (d *ThermostatDevice) GetCurrentTemperature() (float64, error) {
+	if _, err := d.Refresh(); err != nil {
+		return 0, err
+	}
+	if d.GetCF("") == "f" {
+		return d.State.TemperatureF, nil
+	}
+	return d.State.TemperatureC, nil
+}
+
+// GetTargetTemperature refreshes the state and returns the setpoint for the
+// system mode in the system units.
+func (d *ThermostatDevice) GetTargetTemperature() (float64, error) {
+	if _, err := d.Refresh(); err != nil {
+		return 0, err
+	}
+	s := d.State
+	f := d.GetCF("") == "f"
+	switch {
+	case s.Mode == "cool" && f:
+		return s.CoolingSetpointF, nil
+	case s.Mode == "cool":
+		return s.CoolingSetpointC, nil
+	case (s.Mode == "heat" || s.Mode == "emergencyheat") && f:
+		return s.HeatingSetpointF, nil
+	case s.Mode == "heat" || s.Mode == "emergencyheat":
+		return s.HeatingSetpointC, nil
+	case f:
+		return s.SetpointF, nil
+	}
+	return s.SetpointC, nil
+}
+
+// SetTargetTemperature sets the setpoint in the system units, like
+// SetSetpoint.
+func (d *ThermostatDevice) SetTargetTemperature(t float64) (map[string]interface{}, error) {
+	return d.SetSetpoint(t, "")
+}
+
+// SetCoolSetpoint sets the cooling setpoint, used in cool and auto modes.
+func (d *ThermostatDevice) SetCoolSetpoint(setpoint float64, cf string) (map[string]interface{}, error) {
+	return d.setValue("cooling_setpoint_"+d.GetCF(cf), setpoint)
+}
+
+// SetHeatSetpoint sets the heating setpoint, used in heat and auto modes.
+func (d *ThermostatDevice) SetHeatSetpoint(setpoint float64, cf string) (map[string]interface{}, error) {
+	return d.setValue("heating_setpoint_"+d.GetCF(cf), setpoint)
+}
+
+// SetMiddleSetpoint sets the setpoint between cooling and heating. This is
+// normally handled by the thermostat.
+func (d *ThermostatDevice) SetMiddleSetpoint(setpoint float64, cf string) (map[string]interface{}, error) {
+	return d.setValue("setpoint_"+d.GetCF(cf), setpoint)
 }
 
 // SetMode sets the system mode.
 func (d *ThermostatDevice) SetMode(mode string) (map[string]interface{}, error) {
-	return d.SetValue(2, mode)
+	return d.setValue("mode", mode)
 }
 
 // SetFan sets the fan mode.
 func (d *ThermostatDevice) SetFan(fan string) (map[string]interface{}, error) {
-	return d.SetValue(115, fan)
+	return d.setValue("fan", fan)
+}
+
+// SetFanRuntime sets how many minutes per hour the fan runs to circulate
+// the air.
+func (d *ThermostatDevice) SetFanRuntime(minutes int) (map[string]interface{}, error) {
+	return d.setValue("fan_run_time", minutes)
+}
+
+// SetUnits sets the system temperature units, "c" or "f".
+func (d *ThermostatDevice) SetUnits(cf string) (map[string]interface{}, error) {
+	return d.setValue("temp_unit_convert", d.GetCF(cf))
 }
 
 // SetSchedule enables or disables the schedule.
 func (d *ThermostatDevice) SetSchedule(enabled bool) (map[string]interface{}, error) {
-	return d.SetValue(119, enabled)
+	return d.setValue("schedule_enabled", enabled)
 }
 
 // SetHold sets the temperature hold.
 func (d *ThermostatDevice) SetHold(hold string) (map[string]interface{}, error) {
-	return d.SetValue(120, hold)
+	return d.setValue("hold", hold)
+}
+
+// SetVacation writes the raw vacation settings.
+func (d *ThermostatDevice) SetVacation(vacation []byte) (map[string]interface{}, error) {
+	return d.setValue("vacation", vacation)
+}
+
+// SaveSchedule writes Schedule to the device.
+func (d *ThermostatDevice) SaveSchedule() (map[string]interface{}, error) {
+	if d.Schedule == nil {
+		return nil, fmt.Errorf("no schedule to save")
+	}
+	return d.setValue("schedule", d.Schedule.Bytes())
+}
+
+// SaveSensorList writes a sensor list, such as after renaming a sensor.
+func (d *ThermostatDevice) SaveSensorList(l *ThermostatSensorList) (map[string]interface{}, error) {
+	return d.SetMultipleValues(map[string]interface{}{l.DPS: l.Base64()})
+}
+
+// setValue sets a named value, or queues it after DelayUpdates.
+func (d *ThermostatDevice) setValue(key string, value interface{}) (map[string]interface{}, error) {
+	return d.SetValues(map[string]interface{}{key: value})
+}
+
+// lookupDP finds the DPS for a name or alternative name, matching the
+// device's setpoint resolution.
+func (d *ThermostatDevice) lookupDP(key string) (string, thermostatDP, bool) {
+	for _, dp := range thermostatDPS {
+		if dp.name != key && dp.alt != key {
+			continue
+		}
+		if dp.res == thermostatResAny || dp.res == d.highRes {
+			return dp.id, dp.thermostatDP, true
+		}
+	}
+	return "", thermostatDP{}, false
+}
+
+// ParseValue converts a named value into its DPS and the value the DPS
+// expects, checking it against the allowed values and applying scaling and
+// Base64 encoding.
+func (d *ThermostatDevice) ParseValue(key string, value interface{}) (string, interface{}, error) {
+	dps, dp, ok := d.lookupDP(key)
+	if !ok {
+		if d.highRes == thermostatResAny {
+			return "", nil, fmt.Errorf("key %q not found, the setpoint resolution is not known until the status is read", key)
+		}
+		return "", nil, fmt.Errorf("key %q not found", key)
+	}
+
+	if dp.enum != nil {
+		s, _ := value.(string)
+		if !containsString(dp.enum, s) {
+			return "", nil, fmt.Errorf("value %v for %q must be one of %v", value, key, dp.enum)
+		}
+	}
+	if dp.scale != 0 {
+		f, ok := toFloat(value)
+		if !ok {
+			return "", nil, fmt.Errorf("value for %q must be a number", key)
+		}
+		value = int(math.Round(f * dp.scale))
+	}
+	if dp.base64 {
+		b, ok := value.([]byte)
+		if !ok {
+			return "", nil, fmt.Errorf("value for %q must be bytes", key)
+		}
+		value = base64.StdEncoding.EncodeToString(b)
+	}
+	return dps, value, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// Refresh requests the status and updates State, Schedule and the sensors.
+func (d *ThermostatDevice) Refresh() (*ThermostatUpdate, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	return d.Update(dps)
+}
+
+// ReceiveUpdate waits for an asynchronous update, such as a sensor
+// broadcast, and applies it.
+func (d *ThermostatDevice) ReceiveUpdate(timeout time.Duration) (*ThermostatUpdate, error) {
+	resp, err := d.Receive(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return d.Update(resp.DPS)
+}
+
+// SendPing sends a heartbeat without waiting for the reply.
+func (d *ThermostatDevice) SendPing() error {
+	return d.Send(core.HEART_BEAT, nil)
+}
+
+// SendStatusRequest requests the status without waiting for the reply, which
+// can be read with ReceiveUpdate.
+func (d *ThermostatDevice) SendStatusRequest() error {
+	return d.Send(core.DP_QUERY, nil)
+}
+
+// Update applies reported DPS values and returns what changed.
+func (d *ThermostatDevice) Update(dps map[string]interface{}) (*ThermostatUpdate, error) {
+	d.init()
+	update := &ThermostatUpdate{DPS: dps}
+	values := core.DPS(dps)
+
+	for _, l := range d.SensorLists {
+		if !values.Has(l.DPS) {
+			continue
+		}
+		v, err := values.String(l.DPS)
+		if err != nil {
+			return nil, err
+		}
+		changed, err := l.Update(v)
+		if err != nil {
+			return nil, err
+		}
+		update.ChangedSensors = append(update.ChangedSensors, changed...)
+	}
+
+	if d.highRes == thermostatResAny {
+		for _, dp := range thermostatDPS {
+			if _, ok := dps[dp.id]; ok && dp.res != thermostatResAny {
+				d.highRes = dp.res
+				break
+			}
+		}
+	}
+
+	for _, dp := range thermostatDPS {
+		v, ok := dps[dp.id]
+		if !ok {
+			continue
+		}
+		if old, seen := d.raw[dp.id]; seen && reflect.DeepEqual(old, v) {
+			continue
+		}
+		if err := d.applyValue(values, dp.id, dp.thermostatDP); err != nil {
+			return nil, err
+		}
+		d.raw[dp.id] = v
+		update.Changed = append(update.Changed, dp.name)
+		if dp.alt != "" {
+			update.Changed = append(update.Changed, dp.alt)
+		}
+	}
+	return update, nil
+}
+
+// applyValue decodes a DPS value into State or Schedule.
+func (d *ThermostatDevice) applyValue(dps core.DPS, id string, dp thermostatDP) error {
+	var err error
+	num := func() float64 {
+		var f float64
+		if dp.decInt {
+			var n int
+			n, err = dps.Int(id)
+			f = float64(n)
+		} else {
+			f, err = dps.Float(id)
+		}
+		if dp.scale != 0 {
+			f /= dp.scale
+		}
+		return f
+	}
+	str := func() string {
+		var s string
+		s, err = dps.String(id)
+		return s
+	}
+	st := &d.State
+
+	switch dp.name {
+	case "mode":
+		st.Mode = str()
+	case "temp_set":
+		st.SetpointC = num()
+	case "temp_set_f":
+		st.SetpointF = num()
+	case "upper_temp":
+		st.CoolingSetpointC = num()
+	case "upper_temp_f":
+		st.CoolingSetpointF = num()
+	case "lower_temp":
+		st.HeatingSetpointC = num()
+	case "lower_temp_f":
+		st.HeatingSetpointF = num()
+	case "temp_unit_convert":
+		st.Units = str()
+	case "temp_current":
+		st.TemperatureC = num()
+	case "temp_current_f":
+		st.TemperatureF = num()
+	case "temp_correction":
+		st.TempCorrection = num()
+	case "humidity":
+		st.Humidity = int(num())
+	case "fault":
+		st.Fault = int(num())
+	case "system_type":
+		st.SystemType = int(num())
+	case "fan":
+		st.Fan = str()
+	case "home":
+		st.Home = dps[id]
+	case "schedule":
+		data, err := dps.Base64(id)
+		if err != nil {
+			return err
+		}
+		sched, err := DecodeThermostatSchedule(data, d.GetCF(""))
+		if err != nil {
+			return err
+		}
+		d.Schedule = sched
+	case "schedule_enabled":
+		st.ScheduleEnabled, err = dps.Bool(id)
+	case "hold":
+		st.Hold = str()
+	case "vacation":
+		st.Vacation, err = dps.Base64(id)
+	case "fan_run_time":
+		st.FanRunTime = int(num())
+	case "system":
+		st.System = str()
+	case "weather_forcast":
+		st.WeatherForecast = dps[id]
+	}
+	return err
 }
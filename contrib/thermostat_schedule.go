@@ -0,0 +1,286 @@
+package contrib
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Schedule periods. Only wake through sleep show up in the app or on the
+// thermostat, the extra period is hidden.
+const (
+	SCHEDULE_PERIOD_WAKE  = 0
+	SCHEDULE_PERIOD_AWAY  = 1
+	SCHEDULE_PERIOD_HOME  = 2
+	SCHEDULE_PERIOD_SLEEP = 3
+	SCHEDULE_PERIOD_EXTRA = 4
+)
+
+const (
+	scheduleDays       = 7
+	schedulePeriods    = 5
+	schedulePeriodSize = 7
+
+	// SCHEDULE_TIME_DISABLED is the period time of a disabled period.
+	SCHEDULE_TIME_DISABLED = 0xFFFF
+	// SCHEDULE_TEMP_UNSET is the raw temperature of an unset period.
+	SCHEDULE_TEMP_UNSET = -32768
+)
+
+// SchedulePeriod is a single period of a thermostat schedule. Time is in
+// minutes after midnight. HeatTo and CoolTo are in the schedule units; values
+// outside -100 to 100 are raw values, such as SCHEDULE_TEMP_UNSET.
+type SchedulePeriod struct {
+	Participation uint8
+	Time          int
+	HeatTo        float64
+	CoolTo        float64
+}
+
+// ScheduleDay holds the periods of one day.
+type ScheduleDay [schedulePeriods]SchedulePeriod
+
+// ThermostatSchedule is the weekly schedule from DPS 118, Sunday first.
+//
+// The thermostat does not send the schedule when the status is requested,
+// only when it changes. So either set the entire schedule, or change it in
+// the app or on the thermostat while connected, before editing single days
+// or periods.
+type ThermostatSchedule struct {
+	Days  [scheduleDays]ScheduleDay
+	Units string // "c" or "f", the schedule is stored in C on the device
+}
+
+// emptySchedulePeriod returns a disabled period.
+func emptySchedulePeriod() SchedulePeriod {
+	return SchedulePeriod{
+		Participation: 0xFF,
+		Time:          SCHEDULE_TIME_DISABLED,
+		HeatTo:        SCHEDULE_TEMP_UNSET,
+		CoolTo:        SCHEDULE_TEMP_UNSET,
+	}
+}
+
+// NewThermostatSchedule creates a schedule with every period disabled.
+func NewThermostatSchedule(units string) *ThermostatSchedule {
+	s := &ThermostatSchedule{Units: units}
+	for day := range s.Days {
+		for period := range s.Days[day] {
+			s.Days[day][period] = emptySchedulePeriod()
+		}
+	}
+	return s
+}
+
+// ScheduleDayIndex converts a day name (su, m, tu, w, th, f, sa, case
+// insensitive) to its index, Sunday being 0.
+func ScheduleDayIndex(day string) (int, error) {
+	d := strings.ToLower(day)
+	switch {
+	case strings.HasPrefix(d, "su"):
+		return 0, nil
+	case strings.HasPrefix(d, "m"):
+		return 1, nil
+	case strings.HasPrefix(d, "tu"):
+		return 2, nil
+	case strings.HasPrefix(d, "w"):
+		return 3, nil
+	case strings.HasPrefix(d, "th"):
+		return 4, nil
+	case strings.HasPrefix(d, "f"):
+		return 5, nil
+	case strings.HasPrefix(d, "sa"):
+		return 6, nil
+	}
+	return 0, fmt.Errorf("invalid schedule day: %q", day)
+}
+
+// SchedulePeriodIndex converts a period name (wake, away, home, sleep,
+// extra, case insensitive) to its index.
+func SchedulePeriodIndex(period string) (int, error) {
+	if period != "" {
+		switch strings.ToLower(period)[0] {
+		case 'w':
+			return SCHEDULE_PERIOD_WAKE, nil
+		case 'a':
+			return SCHEDULE_PERIOD_AWAY, nil
+		case 'h':
+			return SCHEDULE_PERIOD_HOME, nil
+		case 's':
+			return SCHEDULE_PERIOD_SLEEP, nil
+		case 'e':
+			return SCHEDULE_PERIOD_EXTRA, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid schedule period: %q", period)
+}
+
+// ParseScheduleTime parses a 24-hour HH:MM[:SS] time into minutes after
+// midnight. A bare number is taken as minutes.
+func ParseScheduleTime(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid schedule time: %q", s)
+	}
+	if len(parts) == 1 {
+		return hours, nil
+	}
+	mins, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid schedule time: %q", s)
+	}
+	return hours*60 + mins, nil
+}
+
+// TimeString returns the period time as 24-hour H:MM, or "" if disabled.
+func (p SchedulePeriod) TimeString() string {
+	if p.Time >= 1440 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%02d", p.Time/60, p.Time%60)
+}
+
+func checkScheduleIndex(day, period int) error {
+	if day < 0 || day >= scheduleDays {
+		return fmt.Errorf("day must be in the range 0-6")
+	}
+	if period < 0 || period >= schedulePeriods {
+		return fmt.Errorf("period must be in the range 0-4")
+	}
+	return nil
+}
+
+// SetPeriod sets a schedule period. An out of range participation on an
+// enabled period is replaced with the period's own flag.
+func (s *ThermostatSchedule) SetPeriod(day, period int, p SchedulePeriod) error {
+	if err := checkScheduleIndex(day, period); err != nil {
+		return err
+	}
+	if p.Participation > 3 && p.Time < 1440 {
+		p.Participation = uint8(period & 3)
+	}
+	s.Days[day][period] = p
+	return nil
+}
+
+// DeletePeriod disables a schedule period.
+func (s *ThermostatSchedule) DeletePeriod(day, period int) error {
+	if err := checkScheduleIndex(day, period); err != nil {
+		return err
+	}
+	s.Days[day][period] = emptySchedulePeriod()
+	return nil
+}
+
+// CopyDay copies all periods of one day to another.
+func (s *ThermostatSchedule) CopyDay(src, dst int) error {
+	if err := checkScheduleIndex(src, 0); err != nil {
+		return err
+	}
+	if err := checkScheduleIndex(dst, 0); err != nil {
+		return err
+	}
+	s.Days[dst] = s.Days[src]
+	return nil
+}
+
+// CopyPeriod copies a single period.
+func (s *ThermostatSchedule) CopyPeriod(srcDay, srcPeriod, dstDay, dstPeriod int) error {
+	if err := checkScheduleIndex(srcDay, srcPeriod); err != nil {
+		return err
+	}
+	if err := checkScheduleIndex(dstDay, dstPeriod); err != nil {
+		return err
+	}
+	s.Days[dstDay][dstPeriod] = s.Days[srcDay][srcPeriod]
+	return nil
+}
+
+// DecodeThermostatSchedule decodes the raw schedule, converting temperatures
+// to units ("c" or "f").
+func DecodeThermostatSchedule(data []byte, units string) (*ThermostatSchedule, error) {
+	if len(data)%scheduleDays != 0 {
+		return nil, fmt.Errorf("schedule data is in an unknown format")
+	}
+	dayLen := len(data) / scheduleDays
+	if dayLen%schedulePeriodSize != 0 || dayLen/schedulePeriodSize > schedulePeriods {
+		return nil, fmt.Errorf("schedule day data is in an unknown format")
+	}
+
+	s := NewThermostatSchedule(units)
+	for dow := 0; dow < scheduleDays; dow++ {
+		day := data[dow*dayLen : (dow+1)*dayLen]
+		for period := 0; period*schedulePeriodSize < len(day); period++ {
+			pd := day[period*schedulePeriodSize:]
+			s.Days[dow][period] = SchedulePeriod{
+				Participation: pd[0],
+				Time:          int(binary.BigEndian.Uint16(pd[1:])),
+				HeatTo:        decodeScheduleTemp(int16(binary.BigEndian.Uint16(pd[3:])), units),
+				CoolTo:        decodeScheduleTemp(int16(binary.BigEndian.Uint16(pd[5:])), units),
+			}
+		}
+	}
+	return s, nil
+}
+
+// decodeScheduleTemp converts a raw temperature (degrees C * 100) to units,
+// leaving out of range values raw.
+func decodeScheduleTemp(raw int16, units string) float64 {
+	if raw <= -10000 || raw >= 10000 {
+		return float64(raw)
+	}
+	t := float64(raw) / 100
+	if units == "f" {
+		t = math.RoundToEven(t*1.8 + 32)
+	}
+	return t
+}
+
+// encodeScheduleTemp converts a temperature in units to the raw value,
+// rounded to half a degree C. Values outside -100 to 100 are already raw.
+func encodeScheduleTemp(t float64, units string) int16 {
+	if t < -100 || t > 100 {
+		return int16(math.RoundToEven(t))
+	}
+	// schedule is in C, so convert from F
+	if units == "f" {
+		t = (t - 32) / 1.8
+	}
+	raw := int(math.RoundToEven(t * 100))
+	mod := ((raw % 50) + 50) % 50
+	raw -= mod
+	if mod >= 25 {
+		raw += 50
+	}
+	return int16(raw)
+}
+
+// Bytes encodes the schedule for DPS 118.
+func (s *ThermostatSchedule) Bytes() []byte {
+	out := make([]byte, 0, scheduleDays*schedulePeriods*schedulePeriodSize)
+	for _, day := range s.Days {
+		for _, p := range day {
+			pd := make([]byte, schedulePeriodSize)
+			pd[0] = p.Participation
+			binary.BigEndian.PutUint16(pd[1:], uint16(p.Time))
+			binary.BigEndian.PutUint16(pd[3:], uint16(encodeScheduleTemp(p.HeatTo, s.Units)))
+			binary.BigEndian.PutUint16(pd[5:], uint16(encodeScheduleTemp(p.CoolTo, s.Units)))
+			out = append(out, pd...)
+		}
+	}
+	return out
+}
+
+// Base64 encodes the schedule as sent in DPS 118.
+func (s *ThermostatSchedule) Base64() string {
+	return base64.StdEncoding.EncodeToString(s.Bytes())
+}
+
+// String returns the encoded schedule as hex.
+func (s *ThermostatSchedule) String() string {
+	return fmt.Sprintf("%X", s.Bytes())
+}
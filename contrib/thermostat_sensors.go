@@ -0,0 +1,246 @@
+package contrib
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Remote sensor schedule participation flags.
+const (
+	SENSOR_PARTICIPATION_WAKE  = 1 << 0
+	SENSOR_PARTICIPATION_AWAY  = 1 << 1
+	SENSOR_PARTICIPATION_HOME  = 1 << 2
+	SENSOR_PARTICIPATION_SLEEP = 1 << 3
+)
+
+const (
+	thermostatSensorSize    = 52
+	thermostatSensorNameLen = 30
+	// the app limits names to 20 characters
+	thermostatSensorNameMax = 20
+)
+
+// ThermostatSensor is a remote temperature sensor from a sensor list DPS.
+type ThermostatSensor struct {
+	ID                 uint32
+	Name               string
+	Enabled            bool
+	Occupied           bool
+	Temperature        float64 // degrees C
+	TemperatureUsed    float64 // rounded temperature used for averaging
+	Online             bool
+	Participation      uint8 // SENSOR_PARTICIPATION_* bitmask
+	Battery            int   // percent
+	FirmwareVersion    int   // version * 10
+	Unknown2           uint8
+	Averaging          bool // taking part in temperature averaging
+	Unknown3           [6]byte
+	RawTemperature     int16 // degrees C * 100
+	RawTemperatureUsed int16
+	Changed            []string // fields changed by the last update
+
+	rawName [thermostatSensorNameLen]byte
+}
+
+// IDString returns the sensor ID as a hex string.
+func (s *ThermostatSensor) IDString() string {
+	return fmt.Sprintf("%08x", s.ID)
+}
+
+// SetName renames the sensor, truncated to 20 bytes without splitting a
+// UTF-8 character.
+func (s *ThermostatSensor) SetName(name string) {
+	n := 0
+	for n < len(name) {
+		_, size := utf8.DecodeRuneInString(name[n:])
+		if n+size > thermostatSensorNameMax {
+			break
+		}
+		n += size
+	}
+	name = name[:n]
+	s.Name = name
+	// names are NUL-padded on the left
+	s.rawName = [thermostatSensorNameLen]byte{}
+	copy(s.rawName[thermostatSensorNameLen-len(name):], name)
+}
+
+// SetParticipation sets (on) or clears (off) participation flags.
+func (s *ThermostatSensor) SetParticipation(flags uint8, on bool) {
+	if on {
+		s.Participation |= flags
+	} else {
+		s.Participation &^= flags
+	}
+}
+
+// HasParticipation reports whether all of the given flags are set.
+func (s *ThermostatSensor) HasParticipation(flags uint8) bool {
+	return s.Participation&flags == flags
+}
+
+// parse decodes a 52-byte sensor record, recording the fields that changed.
+func (s *ThermostatSensor) parse(data []byte) {
+	n := ThermostatSensor{
+		ID:                 binary.BigEndian.Uint32(data[0:]),
+		Enabled:            data[34] != 0,
+		Occupied:           data[35] != 0,
+		RawTemperatureUsed: int16(binary.BigEndian.Uint16(data[36:])),
+		Online:             data[38] != 0,
+		Participation:      data[39],
+		Battery:            int(data[40]),
+		FirmwareVersion:    int(data[41]),
+		Unknown2:           data[42],
+		Averaging:          data[43] != 0,
+		RawTemperature:     int16(binary.BigEndian.Uint16(data[44:])),
+	}
+	copy(n.rawName[:], data[4:34])
+	copy(n.Unknown3[:], data[46:52])
+	n.Name = strings.Trim(string(n.rawName[:]), "\x00")
+
+	if n.RawTemperature == 0 {
+		n.RawTemperature = n.RawTemperatureUsed
+		// this does a pretty good job of matching what the thermostat does
+		n.RawTemperatureUsed = n.RawTemperatureUsed / 50 * 50
+	}
+	n.Temperature = float64(n.RawTemperature) / 100
+	n.TemperatureUsed = float64(n.RawTemperatureUsed) / 100
+
+	var changed []string
+	check := func(name string, differs bool) {
+		if differs {
+			changed = append(changed, name)
+		}
+	}
+	check("id", n.ID != s.ID)
+	check("name", n.rawName != s.rawName)
+	check("enabled", n.Enabled != s.Enabled)
+	check("occupied", n.Occupied != s.Occupied)
+	check("temperature_used", n.RawTemperatureUsed != s.RawTemperatureUsed)
+	check("online", n.Online != s.Online)
+	check("participation", n.Participation != s.Participation)
+	check("battery", n.Battery != s.Battery)
+	check("firmware_version", n.FirmwareVersion != s.FirmwareVersion)
+	check("unknown2", n.Unknown2 != s.Unknown2)
+	check("averaging", n.Averaging != s.Averaging)
+	check("temperature", n.RawTemperature != s.RawTemperature)
+	check("unknown3", n.Unknown3 != s.Unknown3)
+
+	n.Changed = changed
+	*s = n
+}
+
+// Bytes encodes the sensor as a 52-byte record.
+func (s *ThermostatSensor) Bytes() []byte {
+	b := make([]byte, thermostatSensorSize)
+	binary.BigEndian.PutUint32(b[0:], s.ID)
+	copy(b[4:34], s.rawName[:])
+	b[34] = boolByte(s.Enabled)
+	b[35] = boolByte(s.Occupied)
+	binary.BigEndian.PutUint16(b[36:], uint16(s.RawTemperatureUsed))
+	b[38] = boolByte(s.Online)
+	b[39] = s.Participation
+	b[40] = byte(s.Battery)
+	b[41] = byte(s.FirmwareVersion)
+	b[42] = s.Unknown2
+	b[43] = boolByte(s.Averaging)
+	binary.BigEndian.PutUint16(b[44:], uint16(s.RawTemperature))
+	copy(b[46:52], s.Unknown3[:])
+	return b
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ThermostatSensorList is a list of remote sensors as found in DPS 122 and
+// 125-128.
+type ThermostatSensorList struct {
+	DPS     string
+	Sensors []*ThermostatSensor
+
+	// statedCount is the leading count byte, or -1 if the list has none
+	statedCount int
+}
+
+// NewThermostatSensorList creates an empty sensor list for the given DPS.
+func NewThermostatSensorList(dps string) *ThermostatSensorList {
+	return &ThermostatSensorList{DPS: dps, statedCount: -1}
+}
+
+// Update parses a Base64 sensor list and returns the sensors which changed.
+// Their Changed field lists the changed fields, or "sensor_added" for new
+// sensors.
+func (l *ThermostatSensorList) Update(b64 string) ([]*ThermostatSensor, error) {
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sensor list: %w", err)
+	}
+	if len(data) == 0 {
+		l.statedCount = -1
+		l.Sensors = nil
+		return nil, nil
+	}
+
+	lenmod := len(data) % thermostatSensorSize
+	switch lenmod {
+	case 0:
+		l.statedCount = -1
+	case 1:
+		l.statedCount = int(data[0])
+	default:
+		return nil, fmt.Errorf("unhandled sensor list length: %d", len(data))
+	}
+
+	var changed []*ThermostatSensor
+	count := len(data) / thermostatSensorSize
+	for i := 0; i < count; i++ {
+		record := data[lenmod+i*thermostatSensorSize : lenmod+(i+1)*thermostatSensorSize]
+		if i < len(l.Sensors) {
+			if l.Sensors[i].parse(record); len(l.Sensors[i].Changed) > 0 {
+				changed = append(changed, l.Sensors[i])
+			}
+			continue
+		}
+		s := &ThermostatSensor{}
+		s.parse(record)
+		// instead of listing every field, just say it was added
+		s.Changed = []string{"sensor_added"}
+		l.Sensors = append(l.Sensors, s)
+		changed = append(changed, s)
+	}
+	// drop sensors the device no longer reports
+	if len(l.Sensors) > count {
+		l.Sensors = l.Sensors[:count]
+	}
+	return changed, nil
+}
+
+// Bytes encodes the sensor list.
+func (l *ThermostatSensorList) Bytes() []byte {
+	var buf bytes.Buffer
+	if l.statedCount >= 0 {
+		buf.WriteByte(byte(l.statedCount))
+	}
+	for _, s := range l.Sensors {
+		buf.Write(s.Bytes())
+	}
+	return buf.Bytes()
+}
+
+// Base64 encodes the sensor list as sent in its DPS.
+func (l *ThermostatSensorList) Base64() string {
+	return base64.StdEncoding.EncodeToString(l.Bytes())
+}
+
+// String returns the encoded sensor list as hex.
+func (l *ThermostatSensorList) String() string {
+	return fmt.Sprintf("%X", l.Bytes())
+}
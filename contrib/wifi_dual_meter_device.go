@@ -1,20 +1,89 @@
 package contrib
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"tinytuya_go/core"
 )
 
 const (
-	WIFI_DUAL_METER_DPS_FORWARD_ENERGY_TOTAL = "1"
-	WIFI_DUAL_METER_DPS_REVERSE_ENERGY_TOTAL = "2"
-	WIFI_DUAL_METER_DPS_POWER_A              = "101"
-	WIFI_DUAL_METER_DPS_POWER_B              = "105"
-	WIFI_DUAL_METER_DPS_VOLTAGE              = "112"
-	WIFI_DUAL_METER_DPS_CURRENT_A            = "113"
-	WIFI_DUAL_METER_DPS_CURRENT_B            = "114"
-	WIFI_DUAL_METER_DPS_TOTAL_POWER          = "115"
+	WIFI_DUAL_METER_DPS_FORWARD_ENERGY_TOTAL         = "1"
+	WIFI_DUAL_METER_DPS_REVERSE_ENERGY_TOTAL         = "2"
+	WIFI_DUAL_METER_DPS_POWER_A                      = "101"
+	WIFI_DUAL_METER_DPS_DIR_CUR_A                    = "102"
+	WIFI_DUAL_METER_DPS_DIR_CUR_B                    = "104"
+	WIFI_DUAL_METER_DPS_POWER_B                      = "105"
+	WIFI_DUAL_METER_DPS_ENERGY_FORWARD_A             = "106"
+	WIFI_DUAL_METER_DPS_ENERGY_REVERSE_A             = "107"
+	WIFI_DUAL_METER_DPS_ENERGY_FORWARD_B             = "108"
+	WIFI_DUAL_METER_DPS_ENERGY_REVERSE_B             = "109"
+	WIFI_DUAL_METER_DPS_POWER_FACTOR_A               = "110"
+	WIFI_DUAL_METER_DPS_FREQ                         = "111"
+	WIFI_DUAL_METER_DPS_VOLTAGE                      = "112"
+	WIFI_DUAL_METER_DPS_CURRENT_A                    = "113"
+	WIFI_DUAL_METER_DPS_CURRENT_B                    = "114"
+	WIFI_DUAL_METER_DPS_TOTAL_POWER                  = "115"
+	WIFI_DUAL_METER_DPS_VOLTAGE_CALIBRATION          = "116"
+	WIFI_DUAL_METER_DPS_CURRENT_CALIBRATION_A        = "117"
+	WIFI_DUAL_METER_DPS_POWER_CALIBRATION_A          = "118"
+	WIFI_DUAL_METER_DPS_ENERGY_CALIBRATION_A         = "119"
+	WIFI_DUAL_METER_DPS_POWER_FACTOR_B               = "121"
+	WIFI_DUAL_METER_DPS_FREQUENCY_CALIBRATION        = "122"
+	WIFI_DUAL_METER_DPS_CURRENT_CALIBRATION_B        = "123"
+	WIFI_DUAL_METER_DPS_POWER_CALIBRATION_B          = "124"
+	WIFI_DUAL_METER_DPS_ENERGY_CALIBRATION_B         = "125"
+	WIFI_DUAL_METER_DPS_ENERGY_CALIBRATION_REVERSE_A = "127"
+	WIFI_DUAL_METER_DPS_ENERGY_CALIBRATION_REVERSE_B = "128"
+	WIFI_DUAL_METER_DPS_REPORT_RATE                  = "129"
+)
+
+// Current directions reported in DPS 102 and 104.
+const (
+	WIFI_DUAL_METER_DIR_FORWARD = "FORWARD"
+	WIFI_DUAL_METER_DIR_REVERSE = "REVERSE"
 )
 
+// WiFiDualMeterReading holds every register of the meter decoded from a
+// single status poll. Power is signed: negative while the current of a
+// channel flows in reverse, such as when exporting solar power.
+type WiFiDualMeterReading struct {
+	ForwardEnergyTotal float64 // kWh
+	ReverseEnergyTotal float64 // kWh
+
+	PowerA         float64 // W
+	DirectionA     string  // WIFI_DUAL_METER_DIR_*
+	ForwardEnergyA float64 // kWh
+	ReverseEnergyA float64 // kWh
+	PowerFactorA   float64
+	CurrentA       float64 // mA
+
+	PowerB         float64 // W
+	DirectionB     string  // WIFI_DUAL_METER_DIR_*
+	ForwardEnergyB float64 // kWh
+	ReverseEnergyB float64 // kWh
+	PowerFactorB   float64
+	CurrentB       float64 // mA
+
+	Frequency  float64 // Hz
+	Voltage    float64 // V
+	TotalPower float64 // W
+	ReportRate int     // seconds
+
+	VoltageCalibration        float64
+	CurrentCalibrationA       float64
+	PowerCalibrationA         float64
+	EnergyCalibrationA        float64
+	FrequencyCalibration      float64
+	CurrentCalibrationB       float64
+	PowerCalibrationB         float64
+	EnergyCalibrationB        float64
+	EnergyCalibrationReverseA float64
+	EnergyCalibrationReverseB float64
+}
+
 // WiFiDualMeterDevice represents a Tuya WiFi Dual Meter Device.
 type WiFiDualMeterDevice struct {
 	*core.Device
@@ -22,12 +91,11 @@ type WiFiDualMeterDevice struct {
 
 // GetValue returns a value from the device.
 func (d *WiFiDualMeterDevice) GetValue(dpsCode string) (float64, error) {
-	status, err := d.Status()
+	dps, err := d.DPS()
 	if err != nil {
 		return 0, err
 	}
-	val, _ := status["dps"].(map[string]interface{})[dpsCode].(float64)
-	return val, nil
+	return dps.Float(dpsCode)
 }
 
 // GetForwardEnergyTotal returns the total forward energy.
@@ -56,3 +124,172 @@ func (d *WiFiDualMeterDevice) GetPowerA() (float64, error) {
 	}
 	return val / 10, nil
 }
+
+// GetPower returns the combined power of both channels in W, negative while
+// exporting.
+func (d *WiFiDualMeterDevice) GetPower() (float64, error) {
+	r, err := d.Reading()
+	if err != nil {
+		return 0, err
+	}
+	return r.PowerA + r.PowerB, nil
+}
+
+// Reading polls the device status and decodes every register.
+func (d *WiFiDualMeterDevice) Reading() (*WiFiDualMeterReading, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	return ParseWiFiDualMeterReading(dps)
+}
+
+// ParseWiFiDualMeterReading decodes the registers from the DPS of a status
+// response. Missing DPS are left at zero; malformed ones are reported in the
+// error.
+func ParseWiFiDualMeterReading(dps core.DPS) (*WiFiDualMeterReading, error) {
+	var errs []error
+	scaled := func(id string, scale float64) float64 {
+		if !dps.Has(id) {
+			return 0
+		}
+		v, err := dps.Float(id)
+		errs = append(errs, err)
+		return v / scale
+	}
+	direction := func(id string) string {
+		if !dps.Has(id) {
+			return ""
+		}
+		v, err := dps.String(id)
+		errs = append(errs, err)
+		return v
+	}
+	r := &WiFiDualMeterReading{
+		ForwardEnergyTotal: scaled(WIFI_DUAL_METER_DPS_FORWARD_ENERGY_TOTAL, 100),
+		ReverseEnergyTotal: scaled(WIFI_DUAL_METER_DPS_REVERSE_ENERGY_TOTAL, 100),
+
+		PowerA:         scaled(WIFI_DUAL_METER_DPS_POWER_A, 10),
+		ForwardEnergyA: scaled(WIFI_DUAL_METER_DPS_ENERGY_FORWARD_A, 100),
+		ReverseEnergyA: scaled(WIFI_DUAL_METER_DPS_ENERGY_REVERSE_A, 100),
+		PowerFactorA:   scaled(WIFI_DUAL_METER_DPS_POWER_FACTOR_A, 100),
+		CurrentA:       scaled(WIFI_DUAL_METER_DPS_CURRENT_A, 1),
+
+		PowerB:         scaled(WIFI_DUAL_METER_DPS_POWER_B, 10),
+		ForwardEnergyB: scaled(WIFI_DUAL_METER_DPS_ENERGY_FORWARD_B, 100),
+		ReverseEnergyB: scaled(WIFI_DUAL_METER_DPS_ENERGY_REVERSE_B, 100),
+		PowerFactorB:   scaled(WIFI_DUAL_METER_DPS_POWER_FACTOR_B, 100),
+		CurrentB:       scaled(WIFI_DUAL_METER_DPS_CURRENT_B, 1),
+
+		Frequency:  scaled(WIFI_DUAL_METER_DPS_FREQ, 100),
+		Voltage:    scaled(WIFI_DUAL_METER_DPS_VOLTAGE, 10),
+		TotalPower: scaled(WIFI_DUAL_METER_DPS_TOTAL_POWER, 10),
+		ReportRate: int(scaled(WIFI_DUAL_METER_DPS_REPORT_RATE, 1)),
+
+		VoltageCalibration:        scaled(WIFI_DUAL_METER_DPS_VOLTAGE_CALIBRATION, 1000),
+		CurrentCalibrationA:       scaled(WIFI_DUAL_METER_DPS_CURRENT_CALIBRATION_A, 1000),
+		PowerCalibrationA:         scaled(WIFI_DUAL_METER_DPS_POWER_CALIBRATION_A, 1000),
+		EnergyCalibrationA:        scaled(WIFI_DUAL_METER_DPS_ENERGY_CALIBRATION_A, 1000),
+		FrequencyCalibration:      scaled(WIFI_DUAL_METER_DPS_FREQUENCY_CALIBRATION, 1000),
+		CurrentCalibrationB:       scaled(WIFI_DUAL_METER_DPS_CURRENT_CALIBRATION_B, 1000),
+		PowerCalibrationB:         scaled(WIFI_DUAL_METER_DPS_POWER_CALIBRATION_B, 1000),
+		EnergyCalibrationB:        scaled(WIFI_DUAL_METER_DPS_ENERGY_CALIBRATION_B, 1000),
+		EnergyCalibrationReverseA: scaled(WIFI_DUAL_METER_DPS_ENERGY_CALIBRATION_REVERSE_A, 1000),
+		EnergyCalibrationReverseB: scaled(WIFI_DUAL_METER_DPS_ENERGY_CALIBRATION_REVERSE_B, 1000),
+	}
+	r.DirectionA = direction(WIFI_DUAL_METER_DPS_DIR_CUR_A)
+	r.DirectionB = direction(WIFI_DUAL_METER_DPS_DIR_CUR_B)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if r.DirectionA == WIFI_DUAL_METER_DIR_REVERSE {
+		r.PowerA = -r.PowerA
+	}
+	if r.DirectionB == WIFI_DUAL_METER_DIR_REVERSE {
+		r.PowerB = -r.PowerB
+	}
+	return r, nil
+}
+
+// String formats every register with its unit, one per line.
+func (r *WiFiDualMeterReading) String() string {
+	var b strings.Builder
+	line := func(name string, v interface{}, unit string) {
+		if unit != "" {
+			unit = " " + unit
+		}
+		fmt.Fprintf(&b, "%s: %v%s\n", name, v, unit)
+	}
+	line("forward_energy_total", r.ForwardEnergyTotal, "kWh")
+	line("reverse_energy_total", r.ReverseEnergyTotal, "kWh")
+	line("power_a", r.PowerA, "W")
+	line("dir_current_a", r.DirectionA, "")
+	line("dir_current_b", r.DirectionB, "")
+	line("power_b", r.PowerB, "W")
+	line("forward_energy_a", r.ForwardEnergyA, "kWh")
+	line("reverse_energy_a", r.ReverseEnergyA, "kWh")
+	line("forward_energy_b", r.ForwardEnergyB, "kWh")
+	line("reverse_energy_b", r.ReverseEnergyB, "kWh")
+	line("power_factor_a", r.PowerFactorA, "")
+	line("ac_frequency", r.Frequency, "Hz")
+	line("ac_voltage", r.Voltage, "V")
+	line("current_a", r.CurrentA, "mA")
+	line("current_b", r.CurrentB, "mA")
+	line("total_power", r.TotalPower, "W")
+	line("voltage_calibration", r.VoltageCalibration, "")
+	line("current_calibration_a", r.CurrentCalibrationA, "")
+	line("power_calibration_a", r.PowerCalibrationA, "")
+	line("energy_calibration_a", r.EnergyCalibrationA, "")
+	line("power_factor_b", r.PowerFactorB, "")
+	line("frequency_calibration", r.FrequencyCalibration, "")
+	line("current_calibration_b", r.CurrentCalibrationB, "")
+	line("power_calibration_b", r.PowerCalibrationB, "")
+	line("energy_calibration_b", r.EnergyCalibrationB, "")
+	line("energy_calibration_reverse_a", r.EnergyCalibrationReverseA, "")
+	line("energy_calibration_reverse_b", r.EnergyCalibrationReverseB, "")
+	line("report_rate", r.ReportRate, "s")
+	return b.String()
+}
+
+// EnergySample returns the combined signed power and current of both
+// channels from a single poll.
+func (d *WiFiDualMeterDevice) EnergySample() (EnergySample, error) {
+	r, err := d.Reading()
+	if err != nil {
+		return EnergySample{}, err
+	}
+	return EnergySample{
+		Time:    time.Now(),
+		Power:   r.PowerA + r.PowerB,
+		Voltage: r.Voltage,
+		Current: (r.CurrentA + r.CurrentB) / 1000,
+	}, nil
+}
+
+// Channel returns a meter for channel "A" or "B" alone, such as to account
+// for the grid and solar clamps separately.
+func (d *WiFiDualMeterDevice) Channel(channel string) (EnergyMeter, error) {
+	if channel != "A" && channel != "B" {
+		return nil, fmt.Errorf("channel must be A or B, got %q", channel)
+	}
+	return wifiDualMeterChannel{d: d, channel: channel}, nil
+}
+
+type wifiDualMeterChannel struct {
+	d       *WiFiDualMeterDevice
+	channel string
+}
+
+func (c wifiDualMeterChannel) EnergySample() (EnergySample, error) {
+	r, err := c.d.Reading()
+	if err != nil {
+		return EnergySample{}, err
+	}
+	s := EnergySample{Time: time.Now(), Voltage: r.Voltage}
+	if c.channel == "A" {
+		s.Power, s.Current = r.PowerA, r.CurrentA/1000
+	} else {
+		s.Power, s.Current = r.PowerB, r.CurrentB/1000
+	}
+	return s, nil
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Batch collects DPS changes so they can be validated and sent together in
+// a single CONTROL command.
+type Batch struct {
+	dev    *Device
+	values map[string]interface{}
+	errs   []error
+}
+
+// NewBatch starts a batch of changes for the device.
+func (d *Device) NewBatch() *Batch {
+	return &Batch{dev: d, values: make(map[string]interface{})}
+}
+
+// Set queues a raw DPS value. When the device has a mapping for the DPS the
+// value is checked against it, and any error is reported by Send.
+func (b *Batch) Set(dpsID string, value interface{}) *Batch {
+	if m, ok := b.dev.Mapping[dpsID]; ok {
+		if err := m.Validate(value); err != nil {
+			b.errs = append(b.errs, fmt.Errorf("DPS %s: %w", dpsID, err))
+			return b
+		}
+	}
+	b.values[dpsID] = value
+	return b
+}
+
+// SetScaled queues a numeric DPS value given in real units, such as degrees,
+// converting it with the "scale" from the device mapping.
+func (b *Batch) SetScaled(dpsID string, value float64) *Batch {
+	scale, _ := b.dev.Mapping[dpsID].ValueFloat("scale")
+	return b.Set(dpsID, int(math.Round(value*math.Pow(10, scale))))
+}
+
+// AddError records an error found while building the batch, such as by a
+// device-specific setter, to be reported by Send.
+func (b *Batch) AddError(err error) *Batch {
+	b.errs = append(b.errs, err)
+	return b
+}
+
+// Values returns the queued DPS values.
+func (b *Batch) Values() map[string]interface{} {
+	return b.values
+}
+
+// Len returns the number of queued DPS values.
+func (b *Batch) Len() int {
+	return len(b.values)
+}
+
+// Err returns the validation errors found so far.
+func (b *Batch) Err() error {
+	return errors.Join(b.errs...)
+}
+
+// Send validates and sends all queued values in one command, then empties
+// the batch. Nothing is sent if any value failed validation.
+func (b *Batch) Send() (map[string]interface{}, error) {
+	if err := b.Err(); err != nil {
+		return nil, err
+	}
+	if len(b.values) == 0 {
+		return nil, nil
+	}
+	values := b.values
+	b.values = make(map[string]interface{})
+	return b.dev.SetMultipleValues(values)
+}
+
+// Validate checks a value against the DP type, allowed enum values and
+// numeric range.
+func (m DPMapping) Validate(value interface{}) error {
+	switch m.Type {
+	case "Boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean, got %v", m.Code, value)
+		}
+	case "Enum":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string, got %v", m.Code, value)
+		}
+		if r, ok := m.Values["range"].([]interface{}); ok {
+			for _, v := range r {
+				if v == s {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s must be one of %v, got %q", m.Code, r, s)
+		}
+	case "Integer", "Value":
+		var n float64
+		switch v := value.(type) {
+		case int:
+			n = float64(v)
+		case int64:
+			n = float64(v)
+		case float64:
+			n = v
+		default:
+			return fmt.Errorf("%s must be a number, got %v", m.Code, value)
+		}
+		if n != math.Trunc(n) {
+			return fmt.Errorf("%s must be a whole number, got %v", m.Code, n)
+		}
+		if lo, ok := m.ValueFloat("min"); ok && n < lo {
+			return fmt.Errorf("%s must be at least %v, got %v", m.Code, lo, n)
+		}
+		if hi, ok := m.ValueFloat("max"); ok && n > hi {
+			return fmt.Errorf("%s must be at most %v, got %v", m.Code, hi, n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,530 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Bulb DP layouts. Type A bulbs use DPS 1-5 with 8-bit values, type B bulbs
+// use DPS 20-27 with values up to 1000, and type C bulbs are dimmers with
+// only on/off, brightness and colour temperature.
+const (
+	BULB_TYPE_A = "A"
+	BULB_TYPE_B = "B"
+	BULB_TYPE_C = "C"
+)
+
+// Bulb modes.
+const (
+	BULB_MODE_WHITE  = "white"
+	BULB_MODE_COLOUR = "colour"
+	BULB_MODE_SCENE  = "scene"
+	BULB_MODE_MUSIC  = "music"
+)
+
+// Music mode colour transitions.
+const (
+	BULB_MUSIC_JUMP     = 0
+	BULB_MUSIC_GRADIENT = 1
+)
+
+// bulbLayout holds the DPS IDs and value ranges of a bulb type. An empty DPS
+// ID means the bulb type does not have it.
+type bulbLayout struct {
+	on, mode, brightness, colourTemp, colour, scene, timer, music string
+
+	minBrightness, maxBrightness, maxColourTemp int
+}
+
+var bulbLayouts = map[string]bulbLayout{
+	BULB_TYPE_A: {
+		on: "1", mode: "2", brightness: "3", colourTemp: "4", colour: "5",
+		minBrightness: 25, maxBrightness: 255, maxColourTemp: 255,
+	},
+	BULB_TYPE_B: {
+		on: "20", mode: "21", brightness: "22", colourTemp: "23", colour: "24",
+		scene: "25", timer: "26", music: "27",
+		minBrightness: 10, maxBrightness: 1000, maxColourTemp: 1000,
+	},
+	BULB_TYPE_C: {
+		on: "1", brightness: "2", colourTemp: "3",
+		minBrightness: 25, maxBrightness: 255, maxColourTemp: 255,
+	},
+}
+
+// BulbState is the state of a bulb. Fields the bulb type does not have are
+// left at their zero value.
+type BulbState struct {
+	IsOn       bool
+	Mode       string
+	Brightness int
+	ColourTemp int
+	Colour     string // hex encoded, see BulbColourHex
+}
+
+// BulbDevice represents a Tuya based smart bulb.
+type BulbDevice struct {
+	*Device
+	// Type is one of BULB_TYPE_A, BULB_TYPE_B or BULB_TYPE_C, detected from
+	// the device status on first use when empty.
+	Type string
+}
+
+// NewBulbDevice creates a new BulbDevice. bulbType may be empty to detect it.
+func NewBulbDevice(d *Device, bulbType string) (*BulbDevice, error) {
+	b := &BulbDevice{Device: d}
+	if bulbType != "" {
+		if err := b.SetBulbType(bulbType); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// SetBulbType sets the DP layout of the bulb.
+func (b *BulbDevice) SetBulbType(bulbType string) error {
+	if _, ok := bulbLayouts[bulbType]; !ok {
+		return fmt.Errorf("unknown bulb type %q", bulbType)
+	}
+	b.Type = bulbType
+	return nil
+}
+
+// DetectBulbType returns the DP layout matching a device status, or "" if
+// none does.
+func DetectBulbType(dps map[string]interface{}) string {
+	if _, ok := dps["20"]; ok {
+		return BULB_TYPE_B
+	}
+	p := DPS(dps)
+	if !p.Has("1") {
+		return ""
+	}
+	// type A has the mode in DPS 2 and the brightness in DPS 3, type C the
+	// brightness in DPS 2
+	if _, err := p.Float("2"); err == nil {
+		return BULB_TYPE_C
+	}
+	if p.Has("3") {
+		return BULB_TYPE_A
+	}
+	return ""
+}
+
+// layout returns the DP layout, querying the device status to detect it if
+// the bulb type is not known yet.
+func (b *BulbDevice) layout() (bulbLayout, error) {
+	if b.Type == "" {
+		dps, err := b.DPS()
+		if err != nil {
+			return bulbLayout{}, err
+		}
+		t := DetectBulbType(dps)
+		if t == "" {
+			return bulbLayout{}, fmt.Errorf("unable to detect bulb type from DPS %v", dps)
+		}
+		b.Type = t
+	}
+	return bulbLayouts[b.Type], nil
+}
+
+// SwitchOn turns on the bulb.
+func (b *BulbDevice) SwitchOn() (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	return b.SetMultipleValues(map[string]interface{}{l.on: true})
+}
+
+// SwitchOff turns off the bulb.
+func (b *BulbDevice) SwitchOff() (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	return b.SetMultipleValues(map[string]interface{}{l.on: false})
+}
+
+// IsOn returns whether the bulb is on.
+func (b *BulbDevice) IsOn() (bool, error) {
+	state, err := b.State()
+	if err != nil {
+		return false, err
+	}
+	return state.IsOn, nil
+}
+
+// State returns the current state of the bulb.
+func (b *BulbDevice) State() (*BulbState, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	dps, err := b.DPS()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &BulbState{}
+	if state.IsOn, err = dps.Bool(l.on); err != nil {
+		return nil, err
+	}
+	// the other DPS are only reported in the modes which use them
+	var errs []error
+	if l.mode != "" && dps.Has(l.mode) {
+		state.Mode, err = dps.String(l.mode)
+		errs = append(errs, err)
+	}
+	if dps.Has(l.brightness) {
+		state.Brightness, err = dps.Int(l.brightness)
+		errs = append(errs, err)
+	}
+	if dps.Has(l.colourTemp) {
+		state.ColourTemp, err = dps.Int(l.colourTemp)
+		errs = append(errs, err)
+	}
+	if l.colour != "" && dps.Has(l.colour) {
+		state.Colour, err = dps.String(l.colour)
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// SetMode sets the mode to white | colour | scene | music, or scene_1 to
+// scene_4 on type A bulbs.
+func (b *BulbDevice) SetMode(mode string) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	if l.mode == "" {
+		return nil, fmt.Errorf("bulb type %s does not support modes", b.Type)
+	}
+	return b.SetMultipleValues(map[string]interface{}{l.mode: mode})
+}
+
+// SetBrightness sets the brightness, clamped to 25-255 on type A and C bulbs
+// and 10-1000 on type B bulbs. In colour mode the value of the colour is
+// changed instead.
+func (b *BulbDevice) SetBrightness(value int) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	value = clampInt(value, l.minBrightness, l.maxBrightness)
+
+	if l.colour != "" {
+		state, err := b.State()
+		if err != nil {
+			return nil, err
+		}
+		if state.Mode == BULB_MODE_COLOUR {
+			h, s, _, err := BulbHexToHSV(state.Colour, b.Type)
+			if err != nil {
+				return nil, err
+			}
+			v := float64(value) / float64(l.maxBrightness)
+			return b.SetMultipleValues(map[string]interface{}{
+				l.colour: BulbHSVHex(h, s, v, b.Type),
+			})
+		}
+	}
+	return b.SetMultipleValues(map[string]interface{}{l.brightness: value})
+}
+
+// SetBrightnessPercentage sets the brightness as a percentage of the
+// bulb's range.
+func (b *BulbDevice) SetBrightnessPercentage(pct int) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	return b.SetBrightness(percentOf(pct, l.minBrightness, l.maxBrightness))
+}
+
+// SetColourTemp sets the white colour temperature, clamped to 0-255 on type
+// A and C bulbs and 0-1000 on type B bulbs.
+func (b *BulbDevice) SetColourTemp(value int) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	value = clampInt(value, 0, l.maxColourTemp)
+	return b.SetMultipleValues(map[string]interface{}{l.colourTemp: value})
+}
+
+// SetColourTempPercentage sets the white colour temperature as a percentage
+// of the bulb's range, 0 being warmest.
+func (b *BulbDevice) SetColourTempPercentage(pct int) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	return b.SetColourTemp(percentOf(pct, 0, l.maxColourTemp))
+}
+
+// SetWhite switches to white mode with the given brightness and colour
+// temperature in a single command.
+func (b *BulbDevice) SetWhite(brightness, colourTemp int) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{
+		l.brightness: clampInt(brightness, l.minBrightness, l.maxBrightness),
+		l.colourTemp: clampInt(colourTemp, 0, l.maxColourTemp),
+	}
+	if l.mode != "" {
+		values[l.mode] = BULB_MODE_WHITE
+	}
+	return b.SetMultipleValues(values)
+}
+
+// SetWhitePercentage is SetWhite with both values as percentages of the
+// bulb's range.
+func (b *BulbDevice) SetWhitePercentage(brightnessPct, colourTempPct int) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	return b.SetWhite(
+		percentOf(brightnessPct, l.minBrightness, l.maxBrightness),
+		percentOf(colourTempPct, 0, l.maxColourTemp),
+	)
+}
+
+// SetColour switches to colour mode with the given 0-255 RGB colour.
+func (b *BulbDevice) SetColour(r, g, bl int) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	if l.colour == "" {
+		return nil, fmt.Errorf("bulb type %s does not support colour", b.Type)
+	}
+	return b.SetMultipleValues(map[string]interface{}{
+		l.mode:   BULB_MODE_COLOUR,
+		l.colour: BulbColourHex(r, g, bl, b.Type),
+	})
+}
+
+// SetHSV switches to colour mode with the given hue, saturation and value,
+// each in the range 0-1.
+func (b *BulbDevice) SetHSV(h, s, v float64) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	if l.colour == "" {
+		return nil, fmt.Errorf("bulb type %s does not support colour", b.Type)
+	}
+	return b.SetMultipleValues(map[string]interface{}{
+		l.mode:   BULB_MODE_COLOUR,
+		l.colour: BulbHSVHex(h, s, v, b.Type),
+	})
+}
+
+// ColourRGB returns the current colour as 0-255 RGB values.
+func (b *BulbDevice) ColourRGB() (int, int, int, error) {
+	state, err := b.State()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return BulbHexToRGB(state.Colour, b.Type)
+}
+
+// ColourHSV returns the current colour as hue, saturation and value, each in
+// the range 0-1.
+func (b *BulbDevice) ColourHSV() (float64, float64, float64, error) {
+	state, err := b.State()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return BulbHexToHSV(state.Colour, b.Type)
+}
+
+// SetScene selects scene 1-4. Type A bulbs select the scene through the mode.
+// Type B bulbs switch to scene mode and, if data is not empty, load the
+// given scene data into the scene DPS; scene is checked but not sent.
+func (b *BulbDevice) SetScene(scene int, data string) (map[string]interface{}, error) {
+	if scene < 1 || scene > 4 {
+		return nil, fmt.Errorf("scene must be between 1 and 4")
+	}
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	switch b.Type {
+	case BULB_TYPE_A:
+		return b.SetMultipleValues(map[string]interface{}{
+			l.mode: fmt.Sprintf("scene_%d", scene),
+		})
+	case BULB_TYPE_B:
+		values := map[string]interface{}{l.mode: BULB_MODE_SCENE}
+		if data != "" {
+			values[l.scene] = data
+		}
+		return b.SetMultipleValues(values)
+	}
+	return nil, fmt.Errorf("bulb type %s does not support scenes", b.Type)
+}
+
+// SetMusicMode switches to music mode.
+func (b *BulbDevice) SetMusicMode() (map[string]interface{}, error) {
+	return b.SetMode(BULB_MODE_MUSIC)
+}
+
+// SetMusicColour sends a music mode colour, as sent by the app while it
+// listens to music. transition is BULB_MUSIC_JUMP or BULB_MUSIC_GRADIENT and
+// brightness and colourTemp are clamped to 0-1000. Only type B bulbs support
+// it.
+func (b *BulbDevice) SetMusicColour(transition, r, g, bl, brightness, colourTemp int) (map[string]interface{}, error) {
+	l, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	if l.music == "" {
+		return nil, fmt.Errorf("bulb type %s does not support music mode", b.Type)
+	}
+	value := fmt.Sprintf("%d%s%04x%04x",
+		clampInt(transition, BULB_MUSIC_JUMP, BULB_MUSIC_GRADIENT),
+		BulbColourHex(r, g, bl, b.Type),
+		clampInt(brightness, 0, 1000),
+		clampInt(colourTemp, 0, 1000),
+	)
+	return b.SetMultipleValues(map[string]interface{}{l.music: value})
+}
+
+// SetCountdown sets the countdown timer. Only type B bulbs have one.
+func (b *BulbDevice) SetCountdown(dur time.Duration) (map[string]interface{}, error) {
+	t, err := b.countdownTimer()
+	if err != nil {
+		return nil, err
+	}
+	return b.SetCountdownTimer(t, dur)
+}
+
+// GetCountdown returns the time remaining on the countdown timer.
+func (b *BulbDevice) GetCountdown() (time.Duration, error) {
+	t, err := b.countdownTimer()
+	if err != nil {
+		return 0, err
+	}
+	return b.GetCountdownTimer(t)
+}
+
+func (b *BulbDevice) countdownTimer() (Timer, error) {
+	l, err := b.layout()
+	if err != nil {
+		return Timer{}, err
+	}
+	if l.timer == "" {
+		return Timer{}, fmt.Errorf("bulb type %s does not have a countdown timer", b.Type)
+	}
+	dps, _ := strconv.Atoi(l.timer)
+	return Timer{DPS: dps, Unit: time.Second, Max: 24 * time.Hour}, nil
+}
+
+// percentOf maps a 0-100 percentage onto the range lo-hi.
+func percentOf(pct, lo, hi int) int {
+	pct = clampInt(pct, 0, 100)
+	return lo + (hi-lo)*pct/100
+}
+
+// BulbColourHex encodes a 0-255 RGB colour for the colour DPS. Type A bulbs
+// use rrggbb0hhhssvv, type B bulbs hhhhssssvvvv.
+func BulbColourHex(r, g, b int, bulbType string) string {
+	r, g, b = clampInt(r, 0, 255), clampInt(g, 0, 255), clampInt(b, 0, 255)
+	h, s, v := RGBToHSV(r, g, b)
+	if bulbType == BULB_TYPE_A {
+		return fmt.Sprintf("%02x%02x%02x%04x%02x%02x", r, g, b, int(h*360), int(s*255), int(v*255))
+	}
+	return fmt.Sprintf("%04x%04x%04x", int(h*360), int(s*1000), int(v*1000))
+}
+
+// BulbHSVHex encodes a colour given as hue, saturation and value, each in the
+// range 0-1, for the colour DPS.
+func BulbHSVHex(h, s, v float64, bulbType string) string {
+	h, s, v = clampUnit(h), clampUnit(s), clampUnit(v)
+	if bulbType == BULB_TYPE_A {
+		r, g, b := HSVToRGB(h, s, v)
+		return fmt.Sprintf("%02x%02x%02x%04x%02x%02x", r, g, b, int(h*360), int(s*255), int(v*255))
+	}
+	return fmt.Sprintf("%04x%04x%04x", int(h*360), int(s*1000), int(v*1000))
+}
+
+// BulbHexToRGB decodes a colour DPS value into 0-255 RGB values.
+func BulbHexToRGB(hex, bulbType string) (int, int, int, error) {
+	if bulbType == BULB_TYPE_A {
+		if len(hex) < 6 {
+			return 0, 0, 0, fmt.Errorf("invalid colour value %q", hex)
+		}
+		rgb, err := parseHexFields(hex, 2, 2, 2)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return rgb[0], rgb[1], rgb[2], nil
+	}
+	h, s, v, err := BulbHexToHSV(hex, bulbType)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	r, g, b := HSVToRGB(h, s, v)
+	return r, g, b, nil
+}
+
+// BulbHexToHSV decodes a colour DPS value into hue, saturation and value,
+// each in the range 0-1.
+func BulbHexToHSV(hex, bulbType string) (float64, float64, float64, error) {
+	if bulbType == BULB_TYPE_A {
+		if len(hex) != 14 {
+			return 0, 0, 0, fmt.Errorf("invalid colour value %q", hex)
+		}
+		hsv, err := parseHexFields(hex[7:], 3, 2, 2)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return float64(hsv[0]) / 360, float64(hsv[1]) / 255, float64(hsv[2]) / 255, nil
+	}
+	if len(hex) != 12 {
+		return 0, 0, 0, fmt.Errorf("invalid colour value %q", hex)
+	}
+	hsv, err := parseHexFields(hex, 4, 4, 4)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return float64(hsv[0]) / 360, float64(hsv[1]) / 1000, float64(hsv[2]) / 1000, nil
+}
+
+// parseHexFields splits s into hex numbers of the given widths.
+func parseHexFields(s string, widths ...int) ([]int, error) {
+	out := make([]int, 0, len(widths))
+	for _, w := range widths {
+		if len(s) < w {
+			return nil, fmt.Errorf("invalid colour value %q", s)
+		}
+		n, err := strconv.ParseUint(s[:w], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid colour value %q", s)
+		}
+		out = append(out, int(n))
+		s = s[w:]
+	}
+	return out, nil
+}
+
+func clampUnit(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
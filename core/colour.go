@@ -0,0 +1,72 @@
+package core
+
+import "math"
+
+// RGBToHSV converts 0-255 RGB values to hue, saturation and value, each in
+// the range 0-1.
+func RGBToHSV(r, g, b int) (h, s, v float64) {
+	rf, gf, bf := float64(r)/255, float64(g)/255, float64(b)/255
+	maxc := math.Max(rf, math.Max(gf, bf))
+	minc := math.Min(rf, math.Min(gf, bf))
+	v = maxc
+	if maxc == minc {
+		return 0, 0, v
+	}
+	delta := maxc - minc
+	s = delta / maxc
+	rc := (maxc - rf) / delta
+	gc := (maxc - gf) / delta
+	bc := (maxc - bf) / delta
+	switch maxc {
+	case rf:
+		h = bc - gc
+	case gf:
+		h = 2 + rc - bc
+	default:
+		h = 4 + gc - rc
+	}
+	h = h / 6
+	h -= math.Floor(h)
+	return h, s, v
+}
+
+// HSVToRGB converts hue, saturation and value in the range 0-1 to 0-255 RGB
+// values.
+func HSVToRGB(h, s, v float64) (r, g, b int) {
+	var rf, gf, bf float64
+	if s == 0 {
+		rf, gf, bf = v, v, v
+	} else {
+		i := math.Floor(h * 6)
+		f := h*6 - i
+		p := v * (1 - s)
+		q := v * (1 - s*f)
+		t := v * (1 - s*(1-f))
+		switch int(i) % 6 {
+		case 0:
+			rf, gf, bf = v, t, p
+		case 1:
+			rf, gf, bf = q, v, p
+		case 2:
+			rf, gf, bf = p, v, t
+		case 3:
+			rf, gf, bf = p, q, v
+		case 4:
+			rf, gf, bf = t, p, v
+		case 5:
+			rf, gf, bf = v, p, q
+		}
+	}
+	return int(rf * 255), int(gf * 255), int(bf * 255)
+}
+
+// clampInt limits v to the range lo-hi.
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Cover DPS.
+const (
+	COVER_DPS_INDEX_MOVE      = "1"
+	COVER_DPS_INDEX_BACKLIGHT = "101"
+)
+
+// CoverCommands is the set of values a cover motor accepts to open, close
+// and stop.
+type CoverCommands struct {
+	Open, Close, Stop string
+}
+
+// Known cover command sets. Motors differ in the values their control DPS
+// accepts.
+var (
+	COVER_COMMANDS_OPEN_CLOSE = CoverCommands{Open: "open", Close: "close", Stop: "stop"}
+	COVER_COMMANDS_ON_OFF     = CoverCommands{Open: "on", Close: "off", Stop: "stop"}
+	COVER_COMMANDS_NUMERIC    = CoverCommands{Open: "1", Close: "2", Stop: "0"}
+)
+
+var coverCommandSets = []CoverCommands{COVER_COMMANDS_OPEN_CLOSE, COVER_COMMANDS_ON_OFF, COVER_COMMANDS_NUMERIC}
+
+// CoverDevice represents a Tuya based curtain or blind motor.
+type CoverDevice struct {
+	*Device
+	// Switch is the control DPS, usually 1.
+	Switch int
+	// Commands is the command set of the motor, detected on first use when
+	// nil.
+	Commands *CoverCommands
+	// Numeric is set when the control DPS takes integers, in which case the
+	// numeric commands are sent as numbers rather than strings.
+	Numeric bool
+}
+
+// NewCoverDevice creates a new CoverDevice using DPS 1 for control.
+func NewCoverDevice(d *Device) *CoverDevice {
+	return &CoverDevice{Device: d, Switch: 1}
+}
+
+// has reports whether v is one of the commands in the set.
+func (c CoverCommands) has(v string) bool {
+	return v == c.Open || v == c.Close || v == c.Stop
+}
+
+// DetectCommands works out the command set of the motor, first from the
+// allowed values in the mapping and then from the current value of the
+// control DPS. Motors reporting nothing useful are assumed to use on/off.
+// Numeric is set when the DPS is an integer in the mapping or the status.
+func (d *CoverDevice) DetectCommands() (CoverCommands, error) {
+	id := strconv.Itoa(d.Switch)
+	d.Numeric = false
+	if m, ok := d.Mapping[id]; ok {
+		if m.Type == "Integer" || m.Type == "Value" {
+			set := COVER_COMMANDS_NUMERIC
+			d.Commands, d.Numeric = &set, true
+			return set, nil
+		}
+		if r, ok := m.Values["range"].([]interface{}); ok {
+			for _, set := range coverCommandSets {
+				for _, v := range r {
+					if s, _ := v.(string); s == set.Open {
+						d.Commands = &set
+						return set, nil
+					}
+				}
+			}
+		}
+	}
+
+	dps, err := d.DPS()
+	if err != nil {
+		return CoverCommands{}, err
+	}
+	var current string
+	switch v := dps[id].(type) {
+	case string:
+		current = v
+	case float64:
+		current = strconv.Itoa(int(v))
+		d.Numeric = true
+	}
+
+	set := COVER_COMMANDS_ON_OFF
+	// "stop" is shared by the named sets, so only trust it as a last resort
+	for _, s := range coverCommandSets {
+		if current != s.Stop && s.has(current) {
+			set = s
+			break
+		}
+	}
+	if current == COVER_COMMANDS_NUMERIC.Stop || d.Numeric {
+		set = COVER_COMMANDS_NUMERIC
+	}
+	d.Commands = &set
+	return set, nil
+}
+
+// commands returns the command set, detecting it if needed.
+func (d *CoverDevice) commands() (CoverCommands, error) {
+	if d.Commands != nil {
+		return *d.Commands, nil
+	}
+	return d.DetectCommands()
+}
+
+// sendCommand sends one of the values of the command set.
+func (d *CoverDevice) sendCommand(pick func(CoverCommands) string) (map[string]interface{}, error) {
+	c, err := d.commands()
+	if err != nil {
+		return nil, err
+	}
+	if d.Switch < 1 {
+		return nil, fmt.Errorf("invalid cover switch DPS %d", d.Switch)
+	}
+	cmd := pick(c)
+	if d.Numeric {
+		n, err := strconv.Atoi(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("cover command %q is not numeric", cmd)
+		}
+		return d.SetValue(d.Switch, n)
+	}
+	return d.SetValue(d.Switch, cmd)
+}
+
+// OpenCover opens the cover.
+func (d *CoverDevice) OpenCover() (map[string]interface{}, error) {
+	return d.sendCommand(func(c CoverCommands) string { return c.Open })
+}
+
+// CloseCover closes the cover.
+func (d *CoverDevice) CloseCover() (map[string]interface{}, error) {
+	return d.sendCommand(func(c CoverCommands) string { return c.Close })
+}
+
+// StopCover stops the motion of the cover.
+func (d *CoverDevice) StopCover() (map[string]interface{}, error) {
+	return d.sendCommand(func(c CoverCommands) string { return c.Stop })
+}
+
+// SetBacklight turns the switch backlight on or off.
+func (d *CoverDevice) SetBacklight(on bool) (map[string]interface{}, error) {
+	return d.SetValue(101, on)
+}
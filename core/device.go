@@ -1,7 +1,7 @@
 package core
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"time"
 )
@@ -9,6 +9,12 @@ import (
 // Device represents a Tuya device with higher-level functions.
 type Device struct {
 	*XenonDevice
+	Mapping map[string]DPMapping
+	// Info is the device description from devices.json or a scan, if known.
+	Info *DeviceInfo
+	// GuessTimer lets FindTimer fall back to the highest DPS in the status,
+	// as Python's set_timer does, when the mapping has no countdown DPS.
+	GuessTimer bool
 }
 
 // NewDevice creates a new Device.
@@ -22,24 +28,13 @@ func NewDevice(devID, address, localKey, devType string, connectionTimeout time.
 
 // SetStatus sets the status of the device to 'on' or 'off'.
 func (d *Device) SetStatus(on bool, switchNum int) (map[string]interface{}, error) {
-	payload, command := d.generatePayload(CONTROL, map[string]interface{}{
+	resp, err := d.SendReceive(CONTROL, map[string]interface{}{
 		fmt.Sprintf("%d", switchNum): on,
 	})
-	msg := TuyaMessage{
-		Seqno:   d.seqno,
-		Cmd:     uint32(command),
-		Payload: payload,
-	}
-	d.seqno++
-	data, err := d.sendReceive(msg)
 	if err != nil {
 		return nil, err
 	}
-	var result map[string]interface{}
-	if err := json.Unmarshal(data, &result); err != nil {
-		return nil, err
-	}
-	return result, nil
+	return resp.Result, nil
 }
 
 // TurnOn turns the device on.
@@ -54,22 +49,134 @@ func (d *Device) TurnOff(switchNum int) (map[string]interface{}, error) {
 
 // SetValue sets an integer value of any index.
 func (d *Device) SetValue(index int, value interface{}) (map[string]interface{}, error) {
-	payload, command := d.generatePayload(CONTROL, map[string]interface{}{
+	resp, err := d.SendReceive(CONTROL, map[string]interface{}{
 		fmt.Sprintf("%d", index): value,
 	})
-	msg := TuyaMessage{
-		Seqno:   d.seqno,
-		Cmd:     uint32(command),
-		Payload: payload,
+	if err != nil {
+		return nil, err
 	}
-	d.seqno++
-	data, err := d.sendReceive(msg)
+	return resp.Result, nil
+}
+
+// SetMultipleValues sets several DPS values in a single command.
+func (d *Device) SetMultipleValues(values map[string]interface{}) (map[string]interface{}, error) {
+	resp, err := d.SendReceive(CONTROL, values)
 	if err != nil {
 		return nil, err
 	}
-	var result map[string]interface{}
-	if err := json.Unmarshal(data, &result); err != nil {
+	return resp.Result, nil
+}
+
+// UpdateDPS asks the device to refresh the given DPS (DPS 1 if none are
+// given) and collects the asynchronous STATUS reports that follow. It returns
+// once every requested DPS has been reported or the connection timeout
+// elapses, with the reported values merged under "dps". ErrTimeout is
+// returned if no report arrives at all.
+func (d *Device) UpdateDPS(dps ...int) (map[string]interface{}, error) {
+	if len(dps) == 0 {
+		dps = []int{1}
+	}
+
+	resp, err := d.SendReceive(UPDATEDPS, dps)
+	if err != nil && err != ErrTimeout {
 		return nil, err
 	}
-	return result, nil
+
+	merged := make(map[string]interface{})
+	pending := make(map[string]bool)
+	for _, dp := range dps {
+		pending[fmt.Sprintf("%d", dp)] = true
+	}
+	reported := false
+	collect := func(r *Response) {
+		if r == nil || r.Command != STATUS {
+			return
+		}
+		reported = true
+		for k, v := range r.DPS {
+			merged[k] = v
+			delete(pending, k)
+		}
+	}
+	collect(resp)
+
+	deadline := time.Now().Add(d.ConnectionTimeout)
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		r, err := d.Receive(time.Until(deadline))
+		if err == ErrTimeout {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		collect(r)
+	}
+	if !reported {
+		return nil, ErrTimeout
+	}
+	return map[string]interface{}{"dps": merged}, nil
+}
+
+// receivePollInterval bounds each read while waiting for asynchronous
+// reports, so context cancellation is noticed promptly.
+const receivePollInterval = time.Second
+
+// WaitForDPS blocks until the device reports one of the given DPS over the
+// open connection or ctx is done. It returns the reported DPS ID and value.
+func (d *Device) WaitForDPS(ctx context.Context, ids ...string) (string, interface{}, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", nil, err
+		}
+
+		wait := receivePollInterval
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			wait = time.Until(deadline)
+			if wait <= 0 {
+				return "", nil, context.DeadlineExceeded
+			}
+		}
+
+		resp, err := d.Receive(wait)
+		if err == ErrTimeout {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		for _, id := range ids {
+			if v, ok := resp.DPS[id]; ok {
+				return id, v, nil
+			}
+		}
+	}
+}
+
+// Watch calls fn with the DPS of every report the device sends over the open
+// connection until ctx is done or the connection fails. A heartbeat is sent
+// every heartbeat interval, if not 0, to keep the connection open.
+func (d *Device) Watch(ctx context.Context, heartbeat time.Duration, fn func(dps map[string]interface{})) error {
+	lastBeat := time.Now()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if heartbeat > 0 && time.Since(lastBeat) >= heartbeat {
+			if err := d.Send(HEART_BEAT, nil); err != nil {
+				return err
+			}
+			lastBeat = time.Now()
+		}
+
+		resp, err := d.Receive(receivePollInterval)
+		if err == ErrTimeout {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(resp.DPS) > 0 {
+			fn(resp.DPS)
+		}
+	}
 }
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DeviceInfo describes a device as found in devices.json or a network scan.
+type DeviceInfo struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Category    string                 `json:"category"`
+	ProductID   string                 `json:"product_id"`
+	ProductKey  string                 `json:"productKey"`
+	ProductName string                 `json:"product_name"`
+	Model       string                 `json:"model"`
+	Mapping     map[string]DPMapping   `json:"mapping"`
+	DPS         map[string]interface{} `json:"dps"`
+}
+
+// LoadDeviceInfo reads the entry with the given id from a devices.json file.
+func LoadDeviceInfo(path, id string) (*DeviceInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var devices []DeviceInfo
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i := range devices {
+		if devices[i].ID == id {
+			return &devices[i], nil
+		}
+	}
+	return nil, fmt.Errorf("device %s not found in %s", id, path)
+}
+
+// SetInfo sets the device info, and the mapping from it when the device has
+// none yet.
+func (d *Device) SetInfo(info *DeviceInfo) {
+	d.Info = info
+	if len(d.Mapping) == 0 && len(info.Mapping) > 0 {
+		d.Mapping = info.Mapping
+	}
+}
+
+// KnownDPS returns the DPS IDs known without contacting the device: those in
+// the mapping, the device info and the DPS to request.
+func (d *Device) KnownDPS() map[string]bool {
+	known := make(map[string]bool)
+	for id := range d.Mapping {
+		known[id] = true
+	}
+	if d.Info != nil {
+		for id := range d.Info.DPS {
+			known[id] = true
+		}
+	}
+	for id := range d.dpsToRequest {
+		known[id] = true
+	}
+	return known
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrDPSMissing   = errors.New("DPS missing")
+	ErrDPSWrongType = errors.New("DPS has wrong type")
+)
+
+// DPSError is returned when a DPS is missing or its value cannot be read as
+// the requested type. It wraps ErrDPSMissing or ErrDPSWrongType.
+type DPSError struct {
+	ID    string
+	Want  string
+	Value interface{}
+	Err   error
+}
+
+func (e *DPSError) Error() string {
+	if errors.Is(e.Err, ErrDPSMissing) {
+		return fmt.Sprintf("DPS %s missing", e.ID)
+	}
+	return fmt.Sprintf("DPS %s: want %s, got %T %v", e.ID, e.Want, e.Value, e.Value)
+}
+
+func (e *DPSError) Unwrap() error {
+	return e.Err
+}
+
+// DPS holds DPS values keyed by DPS ID, as found in a status response.
+// Its accessors coerce the JSON value to the requested type.
+type DPS map[string]interface{}
+
+// DPSFromStatus returns the DPS of a status response.
+func DPSFromStatus(status map[string]interface{}) (DPS, error) {
+	dps, ok := status["dps"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no DPS in status response: %w", ErrDPSMissing)
+	}
+	return DPS(dps), nil
+}
+
+// DPS queries the device status and returns its DPS.
+func (d *Device) DPS() (DPS, error) {
+	status, err := d.Status()
+	if err != nil {
+		return nil, err
+	}
+	return DPSFromStatus(status)
+}
+
+// Has reports whether the DPS is present.
+func (p DPS) Has(id string) bool {
+	_, ok := p[id]
+	return ok
+}
+
+func (p DPS) get(id, want string) (interface{}, error) {
+	v, ok := p[id]
+	if !ok || v == nil {
+		return nil, &DPSError{ID: id, Want: want, Err: ErrDPSMissing}
+	}
+	return v, nil
+}
+
+func wrongType(id, want string, v interface{}) error {
+	return &DPSError{ID: id, Want: want, Value: v, Err: ErrDPSWrongType}
+}
+
+// Float returns a numeric DPS. Numeric strings are accepted.
+func (p DPS) Float(id string) (float64, error) {
+	v, err := p.get(id, "number")
+	if err != nil {
+		return 0, err
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case json.Number:
+		if f, err := n.Float64(); err == nil {
+			return f, nil
+		}
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(n), 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, wrongType(id, "number", v)
+}
+
+// Int returns a whole numeric DPS. Numeric strings are accepted.
+func (p DPS) Int(id string) (int, error) {
+	f, err := p.Float(id)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) {
+		return 0, wrongType(id, "integer", p[id])
+	}
+	return int(f), nil
+}
+
+// Bool returns a boolean DPS. The strings "true" and "false" are accepted.
+func (p DPS) Bool(id string) (bool, error) {
+	v, err := p.get(id, "bool")
+	if err != nil {
+		return false, err
+	}
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(b)) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, wrongType(id, "bool", v)
+}
+
+// String returns a string DPS.
+func (p DPS) String(id string) (string, error) {
+	v, err := p.get(id, "string")
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", wrongType(id, "string", v)
+	}
+	return s, nil
+}
+
+// JSON decodes a DPS into out. The DPS may hold JSON text or an already
+// decoded object or array. Invalid JSON is reported as ErrDPSWrongType.
+func (p DPS) JSON(id string, out interface{}) error {
+	v, err := p.get(id, "JSON")
+	if err != nil {
+		return err
+	}
+	data, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return wrongType(id, "JSON", v)
+		}
+		data = string(b)
+	}
+	if err := json.Unmarshal([]byte(data), out); err != nil {
+		return errors.Join(wrongType(id, "JSON", v), fmt.Errorf("invalid JSON: %w", err))
+	}
+	return nil
+}
+
+// Base64 returns the decoded bytes of a base64 string DPS. Invalid base64 is
+// reported as ErrDPSWrongType.
+func (p DPS) Base64(id string) ([]byte, error) {
+	s, err := p.String(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Join(wrongType(id, "base64", s), fmt.Errorf("invalid base64: %w", err))
+	}
+	return data, nil
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// detectDPSRanges are the DPS ranges probed by DetectAvailableDPS. Devices
+// usually expose DPS in 1-30 and 100-110, and the request payload is limited
+// to 255 bytes so the ranges are probed separately.
+var detectDPSRanges = [][2]int{{2, 11}, {11, 21}, {21, 31}, {100, 111}}
+
+// DPSReport is the result of a DPS probe, suitable for saving into devices.json.
+type DPSReport struct {
+	ID      string                 `json:"id"`
+	DevType string                 `json:"dev_type"`
+	Version string                 `json:"version"`
+	DPS     map[string]interface{} `json:"dps"`
+}
+
+// DPSIDs returns the detected DPS IDs in numeric order.
+func (r *DPSReport) DPSIDs() []int {
+	ids := make([]int, 0, len(r.DPS))
+	for k := range r.DPS {
+		if id, err := strconv.Atoi(k); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// AddDPSToRequest adds DPS to be included in device22 status requests.
+func (d *XenonDevice) AddDPSToRequest(dps ...int) {
+	for _, dp := range dps {
+		d.dpsToRequest[strconv.Itoa(dp)] = nil
+	}
+}
+
+// SetDPSUsed replaces the DPS included in device22 status requests.
+func (d *XenonDevice) SetDPSUsed(dps map[string]interface{}) {
+	d.dpsToRequest = make(map[string]interface{}, len(dps))
+	for k := range dps {
+		d.dpsToRequest[k] = nil
+	}
+}
+
+// DPSToRequest returns the DPS included in device22 status requests.
+func (d *XenonDevice) DPSToRequest() map[string]interface{} {
+	return d.dpsToRequest
+}
+
+// queryDPS sends a device22-style query listing the given DPS explicitly.
+func (d *XenonDevice) queryDPS(dps map[string]interface{}) (*Response, error) {
+	payload, cmd := d.generatePayloadFor("device22", DP_QUERY, dps)
+	if payload == nil {
+		return nil, fmt.Errorf("failed to build DPS query")
+	}
+	return d.request(payload, cmd)
+}
+
+// DetectAvailableDPS probes which DPS the device actually has by querying
+// DPS ranges with device22-style requests and merging what comes back. The
+// result is stored as the DPS to request and returned as a report.
+func (d *XenonDevice) DetectAvailableDPS() (*DPSReport, error) {
+	found := make(map[string]interface{})
+
+	for _, r := range detectDPSRanges {
+		// DPS 1 is always sent, otherwise the query may fail when none of
+		// the requested DPS exist
+		query := map[string]interface{}{"1": nil}
+		for dp := r[0]; dp < r[1]; dp++ {
+			query[strconv.Itoa(dp)] = nil
+		}
+
+		resp, err := d.queryDPS(query)
+		var retErr *RetcodeError
+		if errors.As(err, &retErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range resp.DPS {
+			found[k] = v
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no DPS detected")
+	}
+
+	d.SetDPSUsed(found)
+
+	return &DPSReport{
+		ID:      d.ID,
+		DevType: d.DevType,
+		Version: d.versionString(),
+		DPS:     found,
+	}, nil
+}
+
+// SaveDPSReport merges a report into the device entry with the same id in a
+// devices.json file, adding the entry if it does not exist.
+func SaveDPSReport(path string, report *DPSReport) error {
+	var devices []map[string]interface{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &devices); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+
+	var entry map[string]interface{}
+	for _, dev := range devices {
+		if dev["id"] == report.ID {
+			entry = dev
+			break
+		}
+	}
+	if entry == nil {
+		entry = map[string]interface{}{"id": report.ID}
+		devices = append(devices, entry)
+	}
+	entry["dev_type"] = report.DevType
+	entry["version"] = report.Version
+	entry["dps"] = report.DPS
+
+	out, err := json.MarshalIndent(devices, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
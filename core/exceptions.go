@@ -1,7 +1,26 @@
 package core
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrDecode = errors.New("decode error")
+	ErrDecode  = errors.New("decode error")
+	ErrTimeout = errors.New("timeout waiting for device")
 )
+
+// RetcodeError is returned when a device answers a command with a non-zero
+// return code.
+type RetcodeError struct {
+	Command int
+	Retcode uint32
+	Payload string
+}
+
+func (e *RetcodeError) Error() string {
+	if e.Payload == "" {
+		return fmt.Sprintf("device returned retcode %d for command 0x%02x", e.Retcode, e.Command)
+	}
+	return fmt.Sprintf("device returned retcode %d for command 0x%02x: %s", e.Retcode, e.Command, e.Payload)
+}
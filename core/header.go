@@ -1,5 +1,11 @@
 package core
 
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+)
+
 // Protocol Versions and Headers
 const (
 	PROTOCOL_VERSION_BYTES_31 = "3.1"
@@ -26,3 +32,85 @@ var PREFIX_6699_BIN = []byte{0x00, 0x00, 0x66, 0x99}
 var SUFFIX_6699_BIN = []byte{0x00, 0x00, 0x99, 0x66}
 
 var NO_PROTOCOL_HEADER_CMDS = []int{DP_QUERY, DP_QUERY_NEW, UPDATEDPS, HEART_BEAT, SESS_KEY_NEG_START, SESS_KEY_NEG_RESP, SESS_KEY_NEG_FINISH, LAN_EXT_STREAM}
+
+// SOURCE_HEADER_LEN is the length of a version header carrying source
+// sequencing: "3.x" followed by CRC, sequence number and source ID.
+const SOURCE_HEADER_LEN = 15
+
+// SourceHeader represents the "3.xCCCCCCCCSSSSSSSSUUUUUUUU" header used by
+// v3.3+ devices for per-source sequencing.
+type SourceHeader struct {
+	Version  string
+	Crc      uint32
+	Seqno    uint32
+	SourceID uint32
+}
+
+// Bytes packs the source header into its 15-byte wire form.
+func (h SourceHeader) Bytes() []byte {
+	b := make([]byte, SOURCE_HEADER_LEN)
+	copy(b, h.Version)
+	binary.BigEndian.PutUint32(b[3:], h.Crc)
+	binary.BigEndian.PutUint32(b[7:], h.Seqno)
+	binary.BigEndian.PutUint32(b[11:], h.SourceID)
+	return b
+}
+
+// IsZero reports whether the header carries no source information, as in a
+// plain version header.
+func (h SourceHeader) IsZero() bool {
+	return h.Crc == 0 && h.Seqno == 0 && h.SourceID == 0
+}
+
+// ParseSourceHeader splits a leading version/source header off a payload.
+// Both the binary form and the 24-character ASCII hex form are accepted.
+// If the payload does not start with a 3.x header it is returned unchanged.
+func ParseSourceHeader(payload []byte) (*SourceHeader, []byte) {
+	if len(payload) < SOURCE_HEADER_LEN || !isVersionHeader(payload) {
+		return nil, payload
+	}
+
+	h := &SourceHeader{Version: string(payload[:3])}
+
+	// ASCII hex form: 3 + 8 + 8 + 8 characters
+	if len(payload) >= 27 && isHexString(payload[3:27]) {
+		crc, _ := strconv.ParseUint(string(payload[3:11]), 16, 32)
+		seq, _ := strconv.ParseUint(string(payload[11:19]), 16, 32)
+		src, _ := strconv.ParseUint(string(payload[19:27]), 16, 32)
+		h.Crc, h.Seqno, h.SourceID = uint32(crc), uint32(seq), uint32(src)
+		return h, payload[27:]
+	}
+
+	h.Crc = binary.BigEndian.Uint32(payload[3:7])
+	h.Seqno = binary.BigEndian.Uint32(payload[7:11])
+	h.SourceID = binary.BigEndian.Uint32(payload[11:15])
+	return h, payload[SOURCE_HEADER_LEN:]
+}
+
+func isVersionHeader(payload []byte) bool {
+	for _, v := range []string{PROTOCOL_VERSION_BYTES_33, PROTOCOL_VERSION_BYTES_34, PROTOCOL_VERSION_BYTES_35} {
+		if bytes.HasPrefix(payload, []byte(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHexString(b []byte) bool {
+	for _, c := range b {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// requiresProtocolHeader reports whether a command carries the 3.x header.
+func requiresProtocolHeader(cmd int) bool {
+	for _, c := range NO_PROTOCOL_HEADER_CMDS {
+		if c == cmd {
+			return false
+		}
+	}
+	return true
+}
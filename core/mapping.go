@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DPMapping describes a single DP from the "mapping" entry in devices.json.
+type DPMapping struct {
+	Code   string                 `json:"code"`
+	Type   string                 `json:"type"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// UnmarshalJSON accepts "values" either as an object or as a JSON string,
+// both of which appear in devices.json files.
+func (m *DPMapping) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Code   string          `json:"code"`
+		Type   string          `json:"type"`
+		Values json.RawMessage `json:"values"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	m.Code = raw.Code
+	m.Type = raw.Type
+	m.Values = nil
+
+	if len(raw.Values) == 0 || string(raw.Values) == "null" {
+		return nil
+	}
+	if raw.Values[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw.Values, &s); err != nil {
+			return err
+		}
+		if s == "" || s == "{}" {
+			return nil
+		}
+		return json.Unmarshal([]byte(s), &m.Values)
+	}
+	return json.Unmarshal(raw.Values, &m.Values)
+}
+
+// ValueFloat returns a numeric entry from Values, such as "min" or "scale".
+func (m DPMapping) ValueFloat(key string) (float64, bool) {
+	switch v := m.Values[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// ValueString returns a string entry from Values, such as "unit".
+func (m DPMapping) ValueString(key string) (string, bool) {
+	v, ok := m.Values[key].(string)
+	return v, ok
+}
+
+// SetMapping sets the DP mapping, keyed by DPS ID, as found in devices.json.
+func (d *Device) SetMapping(mapping map[string]DPMapping) {
+	d.Mapping = mapping
+}
+
+// FindDPS returns the lowest DPS ID whose mapping code contains any of the
+// given substrings, trying the substrings in order.
+func (d *Device) FindDPS(codes ...string) (string, bool) {
+	ids := make([]string, 0, len(d.Mapping))
+	for id := range d.Mapping {
+		ids = append(ids, id)
+	}
+	sortDPSIDs(ids)
+
+	for _, code := range codes {
+		for _, id := range ids {
+			if strings.Contains(d.Mapping[id].Code, code) {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+// sortDPSIDs sorts DPS IDs numerically.
+func sortDPSIDs(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		a, errA := strconv.Atoi(ids[i])
+		b, errB := strconv.Atoi(ids[j])
+		if errA != nil || errB != nil {
+			return ids[i] < ids[j]
+		}
+		return a < b
+	})
+}
@@ -25,18 +25,21 @@ type MessagePayload struct {
 
 // TuyaMessage represents a Tuya message.
 type TuyaMessage struct {
-	Seqno    uint32
-	Cmd      uint32
-	Retcode  uint32
-	Payload  []byte
-	Crc      uint32
-	CrcGood  bool
-	Prefix   uint32
-	IV       []byte
+	Seqno     uint32
+	Cmd       uint32
+	Retcode   uint32
+	Payload   []byte
+	Crc       uint32
+	CrcGood   bool
+	Prefix    uint32
+	IV        []byte
+	Source    *SourceHeader
+	Encrypted bool
 }
 
 // PackMessage packs a TuyaMessage into bytes for protocol 3.3.
-func PackMessage(msg TuyaMessage, key []byte) ([]byte, error) {
+// A non-nil header is prepended in the clear after encryption.
+func PackMessage(msg TuyaMessage, key []byte, header []byte) ([]byte, error) {
 	var buffer bytes.Buffer
 
 	// Encrypt payload for 3.3
@@ -46,8 +49,6 @@ func PackMessage(msg TuyaMessage, key []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	// 3.3 header
-	header := append([]byte(PROTOCOL_VERSION_BYTES_33), PROTOCOL_3x_HEADER...)
 	buffer.Write(header)
 	buffer.Write(encryptedPayload)
 
@@ -85,8 +86,15 @@ func UnpackMessage(data []byte, key []byte) (*TuyaMessage, error) {
 	if header.Prefix != PREFIX_VALUE {
 		return nil, fmt.Errorf("invalid prefix")
 	}
+	if header.Length < 12 {
+		return nil, fmt.Errorf("message too short")
+	}
 
-	payload := make([]byte, header.Length-8)
+	// Device to client frames carry a return code ahead of the payload
+	var retcode uint32
+	binary.Read(reader, binary.BigEndian, &retcode)
+
+	payload := make([]byte, header.Length-12)
 	reader.Read(payload)
 
 	var crc uint32
@@ -102,39 +110,34 @@ func UnpackMessage(data []byte, key []byte) (*TuyaMessage, error) {
 	calculatedCrc := crc32.ChecksumIEEE(data[:len(data)-8])
 	crcGood := calculatedCrc == crc
 
-	// Decrypt payload for 3.3
-	var decryptedPayload []byte
-	if len(payload) > 0 {
-		// Remove 3.3 header
-		if bytes.HasPrefix(payload, []byte(PROTOCOL_VERSION_BYTES_33)) {
-			payload = payload[len(PROTOCOL_VERSION_BYTES_33)+len(PROTOCOL_3x_HEADER):]
-			cipher := NewAESCipher(key)
-			var err error
-			decryptedPayload, err = cipher.Decrypt(payload, false)
-			if err != nil {
-				// Try to decode as JSON if decryption fails
-				return &TuyaMessage{
-					Seqno:   header.Seqno,
-					Cmd:     header.Cmd,
-					Payload: payload, // return raw payload
-					Crc:     crc,
-					CrcGood: crcGood,
-					Prefix:  header.Prefix,
-				}, nil
-			}
-		} else {
-			decryptedPayload = payload
-		}
-	}
-
-	return &TuyaMessage{
+	msg := &TuyaMessage{
 		Seqno:   header.Seqno,
 		Cmd:     header.Cmd,
-		Payload: decryptedPayload,
+		Retcode: retcode,
+		Payload: payload,
 		Crc:     crc,
 		CrcGood: crcGood,
 		Prefix:  header.Prefix,
-	}, nil
+	}
+
+	// Plaintext JSON needs no decryption
+	if len(payload) == 0 || payload[0] == '{' {
+		return msg, nil
+	}
+
+	// Remove the clear 3.x header, then decrypt
+	msg.Source, payload = ParseSourceHeader(payload)
+	msg.Encrypted = true
+	cipher := NewAESCipher(key)
+	decryptedPayload, err := cipher.Decrypt(payload, false)
+	if err != nil {
+		// Return the raw payload if decryption fails
+		msg.Payload = payload
+		return msg, nil
+	}
+	msg.Payload = decryptedPayload
+
+	return msg, nil
 }
 
 // PackPlaintext55AA packs a TuyaMessage with a plaintext payload into a 55AA frame.
@@ -300,11 +303,12 @@ func UnpackMessage6699(data []byte, sessionKey []byte) (*TuyaMessage, error) {
 	}
 
 	return &TuyaMessage{
-		Seqno:   seqno,
-		Cmd:     cmd,
-		Retcode: retcode,
-		Payload: payload,
-		Prefix:  prefix,
-		IV:      iv,
+		Seqno:     seqno,
+		Cmd:       cmd,
+		Retcode:   retcode,
+		Payload:   payload,
+		Prefix:    prefix,
+		IV:        iv,
+		Encrypted: true,
 	}, nil
 }
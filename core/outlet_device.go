@@ -0,0 +1,156 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OutletDevice represents a Tuya based smart plug, switch or power strip.
+type OutletDevice struct {
+	*Device
+	gangs []int
+}
+
+// NewOutletDevice creates a new OutletDevice.
+func NewOutletDevice(d *Device) *OutletDevice {
+	return &OutletDevice{Device: d}
+}
+
+// Gangs returns the switch DPS of the outlet in numeric order. Switches are
+// taken from the mapping when one is set, otherwise they are the boolean DPS
+// 1-9 in the device status. The result is cached.
+func (d *OutletDevice) Gangs() ([]int, error) {
+	if d.gangs != nil {
+		return d.gangs, nil
+	}
+	var gangs []int
+	if len(d.Mapping) > 0 {
+		for id, m := range d.Mapping {
+			if m.Type != "Boolean" || !isGangCode(m.Code) {
+				continue
+			}
+			if n, err := strconv.Atoi(id); err == nil {
+				gangs = append(gangs, n)
+			}
+		}
+	} else {
+		dps, err := d.DPS()
+		if err != nil {
+			return nil, err
+		}
+		for id, v := range dps {
+			n, err := strconv.Atoi(id)
+			if err != nil || n < 1 || n > 9 {
+				continue
+			}
+			if _, ok := v.(bool); ok {
+				gangs = append(gangs, n)
+			}
+		}
+	}
+	if len(gangs) == 0 {
+		return nil, fmt.Errorf("no switches found")
+	}
+	sort.Ints(gangs)
+	d.gangs = gangs
+	return gangs, nil
+}
+
+// isGangCode reports whether a mapping code is a switch, such as "switch" or
+// "switch_2", rather than "switch_led" and the like.
+func isGangCode(code string) bool {
+	if code == "switch" {
+		return true
+	}
+	n, ok := strings.CutPrefix(code, "switch_")
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(n)
+	return err == nil
+}
+
+// GangStates returns the on/off state of each switch, keyed by DPS.
+func (d *OutletDevice) GangStates() (map[int]bool, error) {
+	gangs, err := d.Gangs()
+	if err != nil {
+		return nil, err
+	}
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[int]bool, len(gangs))
+	for _, g := range gangs {
+		if states[g], err = dps.Bool(strconv.Itoa(g)); err != nil {
+			return nil, err
+		}
+	}
+	return states, nil
+}
+
+// TurnOnAll turns on every switch in a single command.
+func (d *OutletDevice) TurnOnAll() (map[string]interface{}, error) {
+	return d.setAll(true)
+}
+
+// TurnOffAll turns off every switch in a single command.
+func (d *OutletDevice) TurnOffAll() (map[string]interface{}, error) {
+	return d.setAll(false)
+}
+
+// SwitchOn turns on every switch, like TurnOnAll.
+func (d *OutletDevice) SwitchOn() (map[string]interface{}, error) {
+	return d.setAll(true)
+}
+
+// SwitchOff turns off every switch, like TurnOffAll.
+func (d *OutletDevice) SwitchOff() (map[string]interface{}, error) {
+	return d.setAll(false)
+}
+
+// IsOn reports whether any switch is on.
+func (d *OutletDevice) IsOn() (bool, error) {
+	states, err := d.GangStates()
+	if err != nil {
+		return false, err
+	}
+	for _, on := range states {
+		if on {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *OutletDevice) setAll(on bool) (map[string]interface{}, error) {
+	gangs, err := d.Gangs()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(gangs))
+	for _, g := range gangs {
+		values[strconv.Itoa(g)] = on
+	}
+	return d.SetMultipleValues(values)
+}
+
+// SetDimmer sets the dimmer level in dpsID (usually 3) to a 0-100 percentage.
+// A level of 0 turns the outlet off, other levels turn it on and are clamped
+// to 25-255.
+func (d *OutletDevice) SetDimmer(percentage int, dpsID int) (map[string]interface{}, error) {
+	return d.SetDimmerValue(percentage*255/100, dpsID)
+}
+
+// SetDimmerValue sets the dimmer level in dpsID to a raw 0-255 value.
+func (d *OutletDevice) SetDimmerValue(level int, dpsID int) (map[string]interface{}, error) {
+	if level <= 0 {
+		return d.TurnOff(1)
+	}
+	if _, err := d.TurnOn(1); err != nil {
+		return nil, err
+	}
+	return d.SetValue(dpsID, clampInt(level, 25, 255))
+}
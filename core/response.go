@@ -0,0 +1,57 @@
+package core
+
+import (
+	"encoding/json"
+)
+
+// Response represents a decoded reply from a device.
+type Response struct {
+	Command   int
+	Seqno     uint32
+	Retcode   uint32
+	Encrypted bool
+	CrcGood   bool
+	Source    *SourceHeader
+	Payload   []byte
+	Result    map[string]interface{}
+	DPS       map[string]interface{}
+}
+
+// newResponse decodes an unpacked message into a Response.
+func newResponse(msg *TuyaMessage) *Response {
+	r := &Response{
+		Command:   int(msg.Cmd),
+		Seqno:     msg.Seqno,
+		Retcode:   msg.Retcode,
+		Encrypted: msg.Encrypted,
+		CrcGood:   msg.CrcGood,
+		Source:    msg.Source,
+		Payload:   msg.Payload,
+	}
+
+	if len(msg.Payload) == 0 {
+		return r
+	}
+	if err := json.Unmarshal(msg.Payload, &r.Result); err != nil {
+		return r
+	}
+
+	if dps, ok := r.Result["dps"].(map[string]interface{}); ok {
+		r.DPS = dps
+	} else if data, ok := r.Result["data"].(map[string]interface{}); ok {
+		// v3.4+ nest the DPS inside "data"
+		if dps, ok := data["dps"].(map[string]interface{}); ok {
+			r.DPS = dps
+			r.Result["dps"] = dps
+		}
+	}
+	return r
+}
+
+// Err returns a RetcodeError if the device reported a failure.
+func (r *Response) Err() error {
+	if r.Retcode == 0 {
+		return nil
+	}
+	return &RetcodeError{Command: r.Command, Retcode: r.Retcode, Payload: string(r.Payload)}
+}
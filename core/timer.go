@@ -0,0 +1,145 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Timer describes a countdown DPS and the unit its value is counted in.
+type Timer struct {
+	DPS  int
+	Unit time.Duration
+	Max  time.Duration
+}
+
+func (t Timer) toValue(dur time.Duration) (int, error) {
+	if dur < 0 {
+		return 0, fmt.Errorf("timer duration must not be negative")
+	}
+	if t.Max > 0 && dur > t.Max {
+		return 0, fmt.Errorf("timer duration must be at most %s", t.Max)
+	}
+	unit := t.Unit
+	if unit == 0 {
+		unit = time.Second
+	}
+	return int(math.Round(float64(dur) / float64(unit))), nil
+}
+
+func (t Timer) fromValue(v float64) time.Duration {
+	unit := t.Unit
+	if unit == 0 {
+		unit = time.Second
+	}
+	return time.Duration(v * float64(unit))
+}
+
+// timerUnit converts a mapping unit to a duration, defaulting to seconds.
+func timerUnit(unit string) time.Duration {
+	switch unit {
+	case "min", "m", "minute", "minutes":
+		return time.Minute
+	case "h", "hour", "hours":
+		return time.Hour
+	}
+	return time.Second
+}
+
+// FindTimer locates the countdown DPS from the "countdown" code in the
+// mapping. Without one, the highest DPS in the status is used if GuessTimer
+// is set. Guessing is opt-in as the DPS IDs used for countdowns are
+// brightness or level DPS on other devices.
+func (d *Device) FindTimer() (*Timer, error) {
+	id, ok := d.FindDPS("countdown")
+	if !ok {
+		if !d.GuessTimer {
+			return nil, fmt.Errorf("no countdown DPS in the mapping, the timer DPS must be given or GuessTimer set")
+		}
+		return d.guessTimer()
+	}
+	dps, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid countdown DPS %q", id)
+	}
+	m := d.Mapping[id]
+	unit, _ := m.ValueString("unit")
+	t := &Timer{DPS: dps, Unit: timerUnit(unit)}
+	if max, ok := m.ValueFloat("max"); ok {
+		t.Max = t.fromValue(max)
+	}
+	return t, nil
+}
+
+// guessTimer returns the highest DPS in the status as a timer in seconds.
+func (d *Device) guessTimer() (*Timer, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return nil, err
+	}
+	last := 0
+	for id := range dps {
+		if n, err := strconv.Atoi(id); err == nil && n > last {
+			last = n
+		}
+	}
+	if last == 0 {
+		return nil, fmt.Errorf("no DPS in the status to use as the timer")
+	}
+	return &Timer{DPS: last, Unit: time.Second}, nil
+}
+
+func (d *Device) timerFor(dpsID int) (*Timer, error) {
+	if dpsID == 0 {
+		return d.FindTimer()
+	}
+	t := &Timer{DPS: dpsID, Unit: time.Second}
+	if m, ok := d.Mapping[strconv.Itoa(dpsID)]; ok {
+		unit, _ := m.ValueString("unit")
+		t.Unit = timerUnit(unit)
+	}
+	return t, nil
+}
+
+// SetTimer sets a countdown of dur on the timer DPS. A dpsID of 0 locates the
+// timer DPS with FindTimer.
+func (d *Device) SetTimer(dur time.Duration, dpsID int) (map[string]interface{}, error) {
+	t, err := d.timerFor(dpsID)
+	if err != nil {
+		return nil, err
+	}
+	return d.SetCountdownTimer(*t, dur)
+}
+
+// GetTimer returns the time remaining on the timer DPS. A dpsID of 0 locates
+// the timer DPS with FindTimer.
+func (d *Device) GetTimer(dpsID int) (time.Duration, error) {
+	t, err := d.timerFor(dpsID)
+	if err != nil {
+		return 0, err
+	}
+	return d.GetCountdownTimer(*t)
+}
+
+// SetCountdownTimer sets a countdown of dur on the given timer.
+func (d *Device) SetCountdownTimer(t Timer, dur time.Duration) (map[string]interface{}, error) {
+	v, err := t.toValue(dur)
+	if err != nil {
+		return nil, err
+	}
+	return d.SetValue(t.DPS, v)
+}
+
+// GetCountdownTimer returns the time remaining on the given timer.
+func (d *Device) GetCountdownTimer(t Timer) (time.Duration, error) {
+	dps, err := d.DPS()
+	if err != nil {
+		return 0, err
+	}
+	v, err := dps.Float(strconv.Itoa(t.DPS))
+	if err != nil {
+		return 0, err
+	}
+	return t.fromValue(v), nil
+}
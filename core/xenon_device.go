@@ -1,9 +1,11 @@
 package core
 
 import (
+	"bytes"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -34,6 +36,10 @@ type XenonDevice struct {
 	payloadDict          map[int]map[string]interface{}
 	sessionKey           []byte
 	negotiatedSessionKey bool
+	sourceID             uint32
+	sourceSeqno          uint32
+	lastSource           *SourceHeader
+	pending              []byte // bytes of a partly read frame
 }
 
 // NewXenonDevice creates a new XenonDevice.
@@ -58,6 +64,11 @@ func NewXenonDevice(devID, address, localKey, devType string, connectionTimeout
 		dpsToRequest:      make(map[string]interface{}),
 	}
 
+	// Source ID for the v3.3+ source header. The sequence is seeded from the
+	// clock so a restored ID keeps counting upwards across restarts.
+	d.sourceID = newSourceID()
+	d.sourceSeqno = uint32(time.Now().Unix())
+
 	if d.Address == "" || d.Address == "Auto" || d.Address == "0.0.0.0" {
 		// Auto-discover IP address
 		d.autoIP = true
@@ -72,48 +83,154 @@ func NewXenonDevice(devID, address, localKey, devType string, connectionTimeout
 	return d, nil
 }
 
+// newSourceID generates a random non-zero source ID.
+func newSourceID() uint32 {
+	b := make([]byte, 4)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return 1<<31 | uint32(time.Now().UnixNano()&0x7fffffff)
+		}
+		if id := binary.BigEndian.Uint32(b); id > 1 {
+			// 0 bypasses sequence checking and 1 is the device itself
+			return id
+		}
+	}
+}
+
+// SourceID returns the client source ID and the last source sequence number
+// used, so they can be persisted and restored with SetSourceID.
+func (d *XenonDevice) SourceID() (uint32, uint32) {
+	root := d.root()
+	return root.sourceID, root.sourceSeqno
+}
+
+// SetSourceID restores a persisted source ID and sequence number.
+func (d *XenonDevice) SetSourceID(id, seqno uint32) {
+	root := d.root()
+	root.sourceID = id
+	if seqno > root.sourceSeqno {
+		root.sourceSeqno = seqno
+	}
+}
+
+// LastSourceHeader returns the source header of the last STATUS frame received.
+func (d *XenonDevice) LastSourceHeader() *SourceHeader {
+	return d.lastSource
+}
+
+// root returns the device owning the connection; children share the
+// parent's source ID and sequence counter.
+func (d *XenonDevice) root() *XenonDevice {
+	if d.parent != nil {
+		return d.parent.root()
+	}
+	return d
+}
+
+func (d *XenonDevice) versionString() string {
+	return fmt.Sprintf("%.1f", d.Version)
+}
+
+// protocolHeader returns the 3.x source header for an outgoing command, or
+// nil if the version or command does not use one.
+func (d *XenonDevice) protocolHeader(cmd int) []byte {
+	if d.Version < 3.3 || !requiresProtocolHeader(cmd) {
+		return nil
+	}
+	root := d.root()
+	root.sourceSeqno++
+	h := SourceHeader{
+		Version:  d.versionString(),
+		Seqno:    root.sourceSeqno,
+		SourceID: root.sourceID,
+	}
+	return h.Bytes()
+}
 
 // Status returns the device status.
 func (d *XenonDevice) Status() (map[string]interface{}, error) {
-	payload, command := d.generatePayload(DP_QUERY, nil)
-	msg := TuyaMessage{
-		Seqno:   d.seqno,
-		Cmd:     uint32(command),
-		Payload: payload,
-	}
-	d.seqno++
-	data, err := d.sendReceive(msg)
+	resp, err := d.SendReceive(DP_QUERY, nil)
 	if err != nil {
 		return nil, err
 	}
-	var result map[string]interface{}
-	err = json.Unmarshal(data, &result)
-	if err != nil {
-		return nil, err
+	if d.detectDevice22(resp) {
+		// Resend with the device22 payload
+		resp, err = d.SendReceive(DP_QUERY, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.Result == nil {
+		return nil, fmt.Errorf("invalid JSON response: %q", resp.Payload)
+	}
+	return resp.Result, nil
+}
+
+// detectDevice22 switches a default device to device22 if it rejected a
+// DP query with "data unvalid".
+func (d *XenonDevice) detectDevice22(resp *Response) bool {
+	if d.DevType != "default" || (d.Version != 3.3 && d.Version != 3.4) {
+		return false
+	}
+	if !bytes.Contains(resp.Payload, []byte("data unvalid")) {
+		return false
 	}
-	return result, nil
+	d.DevType = "device22"
+	if len(d.dpsToRequest) == 0 {
+		d.dpsToRequest = map[string]interface{}{"1": nil}
+	}
+	return true
 }
 
 // SetValue sets a single DPS value.
 func (d *XenonDevice) SetValue(dpsID string, value interface{}) (map[string]interface{}, error) {
-	payload, command := d.generatePayload(CONTROL, map[string]interface{}{dpsID: value})
+	resp, err := d.SendReceive(CONTROL, map[string]interface{}{dpsID: value})
+	if err != nil {
+		return nil, err
+	}
+	// It's common for control commands to return an empty or non-json payload
+	return resp.Result, nil
+}
+
+// SendReceive sends a command with optional DPS data and returns the decoded
+// response. The data is sent as "dps", or as "dpId" for UPDATEDPS. A non-zero
+// device return code is reported as a *RetcodeError alongside the response.
+func (d *XenonDevice) SendReceive(command int, data interface{}) (*Response, error) {
+	payload, cmd := d.generatePayload(command, data)
+	if payload == nil {
+		return nil, fmt.Errorf("command 0x%02x not supported for device type %q", command, d.DevType)
+	}
+	return d.request(payload, cmd)
+}
+
+// Send sends a command with optional DPS data without waiting for a reply.
+func (d *XenonDevice) Send(command int, data interface{}) error {
+	payload, cmd := d.generatePayload(command, data)
+	if payload == nil {
+		return fmt.Errorf("command 0x%02x not supported for device type %q", command, d.DevType)
+	}
 	msg := TuyaMessage{
 		Seqno:   d.seqno,
-		Cmd:     uint32(command),
+		Cmd:     uint32(cmd),
 		Payload: payload,
 	}
 	d.seqno++
-	data, err := d.sendReceive(msg)
-	if err != nil {
-		return nil, err
+	return d.send(msg)
+}
+
+func (d *XenonDevice) request(payload []byte, cmd int) (*Response, error) {
+	msg := TuyaMessage{
+		Seqno:   d.seqno,
+		Cmd:     uint32(cmd),
+		Payload: payload,
 	}
-	var result map[string]interface{}
-	err = json.Unmarshal(data, &result)
+	d.seqno++
+	unpacked, err := d.sendReceive(msg)
 	if err != nil {
-		// It's common for control commands to return an empty or non-json payload
-		return nil, nil
+		return nil, err
 	}
-	return result, nil
+	resp := newResponse(unpacked)
+	return resp, resp.Err()
 }
 
 var payloadDict = map[string]map[int]map[string]interface{}{
@@ -121,6 +238,7 @@ var payloadDict = map[string]map[int]map[string]interface{}{
 		CONTROL: {"command": map[string]interface{}{"devId": "", "uid": "", "t": ""}},
 		STATUS:  {"command": map[string]interface{}{"gwId": "", "devId": ""}},
 		DP_QUERY: {"command": map[string]interface{}{"gwId": "", "devId": "", "uid": "", "t": ""}},
+		UPDATEDPS: {"command": map[string]interface{}{"dpId": []int{18, 19, 20}}},
 	},
 	"device22": {
 		DP_QUERY: {
@@ -130,13 +248,24 @@ var payloadDict = map[string]map[int]map[string]interface{}{
 	},
 }
 
-func (d *XenonDevice) generatePayload(command int, data map[string]interface{}) ([]byte, int) {
-	jsonCommand, ok := payloadDict[d.DevType][command]["command"].(map[string]interface{})
+func (d *XenonDevice) generatePayload(command int, data interface{}) ([]byte, int) {
+	return d.generatePayloadFor(d.DevType, command, data)
+}
+
+// generatePayloadFor builds a payload using the given device type's entries,
+// falling back to the default entries for commands it does not override.
+func (d *XenonDevice) generatePayloadFor(devType string, command int, data interface{}) ([]byte, int) {
+	entry, ok := payloadDict[devType][command]
+	if !ok {
+		entry = payloadDict["default"][command]
+	}
+
+	jsonCommand, ok := entry["command"].(map[string]interface{})
 	if !ok {
 		return nil, 0
 	}
 
-	commandOverride, ok := payloadDict[d.DevType][command]["command_override"].(int)
+	commandOverride, ok := entry["command_override"].(int)
 	if !ok {
 		commandOverride = command
 	}
@@ -160,7 +289,14 @@ func (d *XenonDevice) generatePayload(command int, data map[string]interface{})
 	}
 
 	if data != nil {
-		jsonData["dps"] = data
+		if _, ok := jsonData["dpId"]; ok {
+			jsonData["dpId"] = data
+		} else {
+			jsonData["dps"] = data
+		}
+	} else if devType == "device22" && command == DP_QUERY {
+		// device22 only answers for the DPS explicitly listed
+		jsonData["dps"] = d.dpsToRequest
 	}
 
 	payload, err := json.Marshal(jsonData)
@@ -306,57 +442,178 @@ func (d *XenonDevice) negotiateSessionKey() error {
 	return nil
 }
 
-func (d *XenonDevice) sendReceive(msg TuyaMessage) ([]byte, error) {
-	if err := d.connect(); err != nil {
+func (d *XenonDevice) sendReceive(msg TuyaMessage) (*TuyaMessage, error) {
+	if err := d.send(msg); err != nil {
 		return nil, err
 	}
+	deadline := time.Now().Add(d.ConnectionTimeout)
+	for {
+		unpacked, err := d.receive(deadline)
+		if err != nil {
+			return nil, err
+		}
+		// skip the empty acks of commands sent earlier without waiting for
+		// their reply, like the null payloads Python's _send_receive skips
+		if len(unpacked.Payload) == 0 && unpacked.Cmd != msg.Cmd {
+			continue
+		}
+		return unpacked, nil
+	}
+}
+
+func (d *XenonDevice) send(msg TuyaMessage) error {
+	if err := d.connect(); err != nil {
+		return err
+	}
 
 	var packed []byte
 	var err error
 
+	header := d.protocolHeader(int(msg.Cmd))
 	if d.Version >= 3.5 {
-		// v3.5 uses 6699 frame with session key
+		// v3.5 uses 6699 frame with session key, header inside ciphertext
+		msg.Payload = append(header, msg.Payload...)
 		packed, err = PackMessage6699(msg, d.sessionKey)
 	} else if d.Version >= 3.4 {
-		// v3.4 uses 55AA frame with session key
-		packed, err = PackMessage(msg, d.sessionKey)
+		// v3.4 uses 55AA frame with session key, header inside ciphertext
+		msg.Payload = append(header, msg.Payload...)
+		packed, err = PackMessage(msg, d.sessionKey, nil)
 	} else {
-		// v3.3 and earlier use 55AA frame with static local key
-		packed, err = PackMessage(msg, d.LocalKey)
+		// v3.3 and earlier use 55AA frame with static local key, header in the clear
+		packed, err = PackMessage(msg, d.LocalKey, header)
 	}
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	_, err = d.socket.Write(packed)
 	if err != nil {
-		return nil, err
+		d.Close()
+		return err
 	}
+	return nil
+}
 
-	response := make([]byte, 4096)
-	n, err := d.socket.Read(response)
+// receive reads and unpacks the next frame, waiting until deadline.
+func (d *XenonDevice) receive(deadline time.Time) (*TuyaMessage, error) {
+	if d.socket == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	d.socket.SetReadDeadline(deadline)
+
+	frame, err := d.readFrame()
+	if isTimeout(err) && len(d.pending) > 0 {
+		// a frame is partly read, so wait for the rest of it
+		d.socket.SetReadDeadline(time.Now().Add(d.ConnectionTimeout))
+		frame, err = d.readFrame()
+	}
 	if err != nil {
+		if isTimeout(err) && len(d.pending) == 0 {
+			return nil, ErrTimeout
+		}
+		d.Close()
 		return nil, err
 	}
 
 	var unpacked *TuyaMessage
 	if d.Version >= 3.5 {
 		// v3.5 uses 6699 frame with session key
-		unpacked, err = UnpackMessage6699(response[:n], d.sessionKey)
+		unpacked, err = UnpackMessage6699(frame, d.sessionKey)
 	} else if d.Version >= 3.4 {
 		// v3.4 uses 55AA frame with session key
-		unpacked, err = UnpackMessage(response[:n], d.sessionKey)
+		unpacked, err = UnpackMessage(frame, d.sessionKey)
 	} else {
 		// v3.3 and earlier use 55AA frame with static local key
-		unpacked, err = UnpackMessage(response[:n], d.LocalKey)
+		unpacked, err = UnpackMessage(frame, d.LocalKey)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return unpacked.Payload, nil
+	// v3.4+ carry the source header inside the ciphertext
+	if unpacked.Source == nil {
+		unpacked.Source, unpacked.Payload = ParseSourceHeader(unpacked.Payload)
+	}
+	if unpacked.Cmd == STATUS && unpacked.Source != nil {
+		d.lastSource = unpacked.Source
+	}
+
+	return unpacked, nil
+}
+
+// isTimeout reports whether err is a read deadline expiring.
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// fill reads from the socket until at least n bytes are pending. Bytes read
+// before an error are kept, so a read interrupted by a deadline can resume.
+func (d *XenonDevice) fill(n int) error {
+	for len(d.pending) < n {
+		buf := make([]byte, n-len(d.pending))
+		k, err := d.socket.Read(buf)
+		d.pending = append(d.pending, buf[:k]...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFrame reads exactly one 55AA or 6699 frame from the socket.
+func (d *XenonDevice) readFrame() ([]byte, error) {
+	if err := d.fill(16); err != nil {
+		return nil, err
+	}
+
+	var headLen int
+	var remaining uint32
+	switch binary.BigEndian.Uint32(d.pending[:4]) {
+	case PREFIX_VALUE:
+		headLen = 16
+		remaining = binary.BigEndian.Uint32(d.pending[12:16])
+	case PREFIX_6699_VALUE:
+		// 6699 has a 2-byte reserved field and its length excludes the suffix
+		if err := d.fill(18); err != nil {
+			return nil, err
+		}
+		headLen = 18
+		remaining = binary.BigEndian.Uint32(d.pending[14:18]) + 4
+	default:
+		return nil, fmt.Errorf("invalid frame prefix: %x", d.pending[:4])
+	}
+
+	if remaining > 4096 {
+		return nil, fmt.Errorf("frame too large: %d bytes", remaining)
+	}
+
+	size := headLen + int(remaining)
+	if err := d.fill(size); err != nil {
+		return nil, err
+	}
+	frame := append([]byte(nil), d.pending[:size]...)
+	d.pending = d.pending[size:]
+	if len(d.pending) == 0 {
+		d.pending = nil
+	}
+	return frame, nil
+}
+
+// Receive waits up to timeout for the next frame from the device, such as an
+// asynchronous STATUS report. ErrTimeout is returned if nothing arrives.
+func (d *XenonDevice) Receive(timeout time.Duration) (*Response, error) {
+	if err := d.connect(); err != nil {
+		return nil, err
+	}
+	unpacked, err := d.receive(time.Now().Add(timeout))
+	if err != nil {
+		return nil, err
+	}
+	resp := newResponse(unpacked)
+	return resp, resp.Err()
 }
 
 // Close closes the device connection and cleans up resources.
@@ -364,6 +621,7 @@ func (d *XenonDevice) Close() error {
 	if d.socket != nil {
 		err := d.socket.Close()
 		d.socket = nil
+		d.pending = nil
 		d.negotiatedSessionKey = false
 		d.sessionKey = nil
 		return err